@@ -1,6 +1,10 @@
 package elevator
 
 import (
+	"reflect"
+	"sort"
+	"time"
+
 	"./eventManager"
 	"./log"
 	"./utils"
@@ -8,7 +12,22 @@ import (
 
 //List with HallUp/HallDown orders for each floor
 type HallOrders struct {
-	Orders [utils.FLOOR_NUM][utils.ORDER_TYPE_NUM - 1]int
+	Orders [][utils.ORDER_TYPE_NUM - 1]int
+}
+
+//newHallOrders returns an empty list of hall orders sized after the number of floors
+func newHallOrders() HallOrders {
+	return HallOrders{make([][utils.ORDER_TYPE_NUM - 1]int, utils.FLOOR_NUM)}
+}
+
+//hallOrdersOf returns the hall orders of an elevator, creating an empty list if it has none
+func hallOrdersOf(elevatorID int) HallOrders {
+	hallOrders, exist := HallOrdersMap[elevatorID]
+	if !exist {
+		hallOrders = newHallOrders()
+		HallOrdersMap[elevatorID] = hallOrders
+	}
+	return hallOrders
 }
 
 //Map over hall orders for the different elevators, sorted by elevator ID
@@ -19,35 +38,191 @@ func ActiveOrdersModule() {
 	log.PrintDbg("Started")
 
 	activeOrdersAnsPub := make(chan ActiveOrdersAnsEvent)
+	hallRequestTablePub := make(chan HallRequestTableEvent)
+	orderLampCtrPub := make(chan OrderLampCtrEvent)
+	rejoinStatePub := make(chan RejoinStateEvent)
+	rejoinDonePub := make(chan RejoinDoneEvent)
+	assignedPub := make(chan AssignedEvent)
+	orderRevokedPub := make(chan OrderRevokedEvent)
+	newOrderPub := make(chan NewOrderEvent)
 
 	activeOrdersReqSub := make(chan ActiveOrdersReqEvent)
 	assignedSub := make(chan AssignedEvent)
 	orderCompleteSub := make(chan OrderCompleteEvent)
 	availabilitySub := make(chan AvailabilityEvent)
 	unavailableOrdersHandledSub := make(chan UnavailableOrdersHandledEvent)
+	newOrderSub := make(chan NewOrderEvent)
+	hallRequestTableSub := make(chan HallRequestTableEvent)
+	connectSub := make(chan ConnectionEvent)
+	rejoinStateSub := make(chan RejoinStateEvent)
+	cabOrdersBackupSub := make(chan CabOrdersBackupEvent)
+	cancelOrderSub := make(chan CancelOrderEvent)
+	orderRevokedSub := make(chan OrderRevokedEvent)
+	handoverCommitSub := make(chan HandoverCommitEvent)
+	leaderChangedSub := make(chan LeaderChangedEvent)
+	partitionSub := make(chan PartitionEvent)
+	epochSub := make(chan EpochEvent)
+	lostOrdersHandledSub := make(chan LostOrdersHandledEvent)
 
-	eventManager.AddPublishers(activeOrdersAnsPub)
-	eventManager.AddSubscribers(activeOrdersReqSub, assignedSub, orderCompleteSub, availabilitySub, unavailableOrdersHandledSub)
+	eventManager.AddPublishers(activeOrdersAnsPub, hallRequestTablePub, orderLampCtrPub, rejoinStatePub, rejoinDonePub, assignedPub, orderRevokedPub,
+		newOrderPub)
+	eventManager.AddSubscribers(activeOrdersReqSub, assignedSub, orderCompleteSub, availabilitySub, unavailableOrdersHandledSub,
+		newOrderSub, hallRequestTableSub, connectSub, rejoinStateSub, cabOrdersBackupSub, cancelOrderSub, orderRevokedSub, handoverCommitSub, leaderChangedSub,
+		partitionSub, epochSub, lostOrdersHandledSub)
 
 	HallOrdersMap = make(map[int]HallOrders)
 
+	table := newHallRequestTable()
+	connected := make(map[int]bool)
+	lamps := make([][utils.ORDER_TYPE_NUM - 1]bool, utils.FLOOR_NUM)
+	tableTicker := time.NewTicker(utils.Config.Network.HallTableInterval.Duration)
+	available := true
+	//copies of the cab orders of all elevators, this one included
+	cabBackups := make(map[int][]int)
+	//trace IDs of the assigned hall orders, passed on if the orders are given to another elevator
+	traces := make([][utils.ORDER_TYPE_NUM - 1]string, utils.FLOOR_NUM)
+	leader := -1
+	epoch := 0
+	//when each outstanding hall call was first seen without an elevator holding it
+	unheld := make(map[hallCall]time.Time)
+
+	// publishTable sends the table to the other elevators and updates the hall lamps to the agreed state
+	publishTable := func() {
+		hallRequestTablePub <- HallRequestTableEvent{utils.ELEVATOR_ID, table.snapshot()}
+		updateHallLamps(table, connected, lamps, orderLampCtrPub)
+	}
+
 	for {
 		select {
 		case evt := <-assignedSub:
 			AddHallOrders(evt)
+			if evt.OrderType != orderCab && evt.TraceID != "" {
+				traces[evt.Floor][evt.OrderType] = evt.TraceID
+			}
+		case evt := <-newOrderSub:
+			if table.press(evt.Floor, evt.OrderType) {
+				publishTable()
+			}
+		case evt := <-hallRequestTableSub:
+			if evt.ElevatorID != utils.ELEVATOR_ID {
+				if table.merge(evt.ElevatorID, evt.Counters) {
+					publishTable()
+				} else {
+					updateHallLamps(table, connected, lamps, orderLampCtrPub)
+				}
+			}
+		case evt := <-connectSub:
+			if evt.Connect {
+				connected[evt.ElevatorID] = true
+				// Give the connecting elevator everything it may have missed while it was away, and the partition
+				// this elevator was in so the connecting elevator can merge it if it was in another
+				members := []int{utils.ELEVATOR_ID}
+				for elevatorID := range connected {
+					if elevatorID != evt.ElevatorID {
+						members = append(members, elevatorID)
+					}
+				}
+				sort.Ints(members)
+				rejoinStatePub <- RejoinStateEvent{utils.ELEVATOR_ID, evt.ElevatorID, available, copyHallOrdersMap(), cabBackups[evt.ElevatorID],
+					epoch, members, table.snapshot(), copyCabBackups(cabBackups), copyTraces(traces)}
+			} else {
+				delete(connected, evt.ElevatorID)
+				table.forget(evt.ElevatorID)
+			}
+			updateHallLamps(table, connected, lamps, orderLampCtrPub)
+		case <-tableTicker.C:
+			publishTable()
+			if leader == utils.ELEVATOR_ID && utils.Config.Assignment.Mode != utils.ModeOptimal && len(ReturnActiveElevatorsID()) > 0 {
+				for _, call := range unheldHallCalls(table, unheld, time.Now()) {
+					// the elevator that got the call may have been lost before it was assigned
+					log.PrintInf("Assigning hall call on floor", call.floor, orderTypeName(call.orderType), "that no elevator has")
+					newOrderPub <- NewOrderEvent{utils.ELEVATOR_ID, call.floor, newOrderID(), call.orderType, traces[call.floor][call.orderType]}
+				}
+			}
 		case evt := <-orderCompleteSub:
 			RemoveFloorHallOrders(evt.Floor)
+			traces[evt.Floor] = [utils.ORDER_TYPE_NUM - 1]string{}
+			if table.complete(evt.Floor) {
+				publishTable()
+			}
+		case evt := <-cancelOrderSub:
+			if evt.OrderType == orderCab {
+				break
+			}
+			for elevatorID := range HallOrdersMap {
+				HallOrdersMap[elevatorID].Orders[evt.Floor][evt.OrderType] = 0
+			}
+			traces[evt.Floor][evt.OrderType] = ""
+			if table.cancel(evt.Floor, evt.OrderType) {
+				publishTable()
+			}
+		case evt := <-orderRevokedSub:
+			// the call is still outstanding, so its trace is kept for the elevator it is given to
+			hallOrdersOf(evt.ElevatorID).Orders[evt.Floor][evt.OrderType] = 0
+		case evt := <-handoverCommitSub:
+			if !validHallOrder(evt.Floor, evt.OrderType) {
+				break
+			}
+			// the order may have been completed while the handover was agreed on
+			if hallOrdersOf(evt.From).Orders[evt.Floor][evt.OrderType] == 0 {
+				log.PrintInf("Handover", evt.HandoverID, "of an order elevator", evt.From, "no longer has")
+				break
+			}
+			orderRevokedPub <- OrderRevokedEvent{evt.From, evt.Floor, evt.OrderType}
+			assignedPub <- AssignedEvent{evt.To, -1, evt.Floor, evt.OrderType, false, traces[evt.Floor][evt.OrderType]}
+		case evt := <-leaderChangedSub:
+			leader = evt.LeaderID
+		case evt := <-partitionSub:
+			if evt.ElevatorID == utils.ELEVATOR_ID {
+				epoch = evt.Epoch
+			}
+		case evt := <-epochSub:
+			if evt.ElevatorID == utils.ELEVATOR_ID {
+				epoch = evt.Epoch
+			}
 		case evt := <-activeOrdersReqSub:
-			hallOrders := HallOrdersMap[evt.ElevatorID]
-			ActiveOrders := ActiveOrdersAnsEvent{evt.ElevatorID, hallOrders.Orders}
+			hallOrders := hallOrdersOf(evt.ElevatorID)
+			orders := make([][utils.ORDER_TYPE_NUM - 1]int, utils.FLOOR_NUM)
+			copy(orders, hallOrders.Orders)
+			orderTraces := make([][utils.ORDER_TYPE_NUM - 1]string, utils.FLOOR_NUM)
+			copy(orderTraces, traces)
+			ActiveOrders := ActiveOrdersAnsEvent{evt.ElevatorID, orders, orderTraces}
 			activeOrdersAnsPub <- ActiveOrders
+		case evt := <-cabOrdersBackupSub:
+			if len(evt.CabOrders) == utils.FLOOR_NUM {
+				cabBackups[evt.ElevatorID] = evt.CabOrders
+			}
+		case evt := <-rejoinStateSub:
+			if evt.TargetID == utils.ELEVATOR_ID && evt.ElevatorID != utils.ELEVATOR_ID {
+				if table.merge(evt.ElevatorID, evt.Counters) {
+					publishTable()
+				}
+				missed, revoked := mergeRejoinState(evt, table, connected, cabBackups)
+				for _, assigned := range missed {
+					assignedPub <- assigned
+				}
+				for _, r := range revoked {
+					orderRevokedPub <- r
+				}
+				updateHallLamps(table, connected, lamps, orderLampCtrPub)
+				rejoinDonePub <- RejoinDoneEvent{utils.ELEVATOR_ID, evt.ElevatorID}
+			}
 		case evt := <-availabilitySub:
+			if evt.ElevatorID == utils.ELEVATOR_ID {
+				available = evt.Availabable
+			}
 			if !singleElevatorAvailable() {
-				if !evt.Availabable && evt.ElevatorID == utils.ELEVATOR_ID {
+				// when this elevator is the leader, it gives out its own orders and deletes them when that is done
+				if !evt.Availabable && evt.ElevatorID == utils.ELEVATOR_ID && leader != utils.ELEVATOR_ID {
 					deleteAllHallOrders(evt.ElevatorID)
 					log.PrintDbg("Deleted hall orders for elev", utils.ELEVATOR_ID, " is ", HallOrdersMap[utils.ELEVATOR_ID])
 				}
 			}
+		case evt := <-lostOrdersHandledSub:
+			// the leader has given the orders to the others with new orders
+			if evt.ElevatorID != utils.ELEVATOR_ID {
+				deleteAllHallOrders(evt.LostID)
+			}
 		case evt := <-unavailableOrdersHandledSub:
 			if evt.Handled {
 				deleteAllHallOrders(evt.ElevatorID)
@@ -56,13 +231,217 @@ func ActiveOrdersModule() {
 	}
 }
 
+//updateHallLamps turns the hall lamps on for calls all connected elevators agree on, and off for calls that are
+//no longer outstanding. Like before, lamps are not turned on when no other elevator is connected.
+func updateHallLamps(table *hallRequestTable, connected map[int]bool, lamps [][utils.ORDER_TYPE_NUM - 1]bool, orderLampCtrPub chan OrderLampCtrEvent) {
+	for floor := range lamps {
+		for orderType := range lamps[floor] {
+			lit := lamps[floor][orderType]
+			if !lit && len(connected) > 0 && table.confirmed(floor, OrderType(orderType), connected) {
+				lamps[floor][orderType] = true
+				orderLampCtrPub <- OrderLampCtrEvent{floor, OrderType(orderType), true}
+			} else if lit && !table.outstanding(floor, OrderType(orderType)) {
+				lamps[floor][orderType] = false
+				orderLampCtrPub <- OrderLampCtrEvent{floor, OrderType(orderType), false}
+			}
+		}
+	}
+}
+
+//unheldHallCalls returns the outstanding hall calls no elevator has had for twice Elevator.MaxDecideTime, which
+//is longer than an assignment takes. unheld holds when each call was first seen without an elevator, a returned
+//call is given that time again so it is only returned once per period.
+func unheldHallCalls(table *hallRequestTable, unheld map[hallCall]time.Time, now time.Time) []hallCall {
+	var calls []hallCall
+	for floor := 0; floor < utils.FLOOR_NUM; floor++ {
+		for orderType := 0; orderType < utils.ORDER_TYPE_NUM-1; orderType++ {
+			call := hallCall{floor, OrderType(orderType)}
+			held := false
+			for _, hallOrders := range HallOrdersMap {
+				held = held || hallOrders.Orders[floor][orderType] == 1
+			}
+			if held || !table.outstanding(floor, OrderType(orderType)) {
+				delete(unheld, call)
+				continue
+			}
+			since, seen := unheld[call]
+			if !seen {
+				unheld[call] = now
+			} else if now.Sub(since) >= 2*utils.Config.Elevator.MaxDecideTime.Duration {
+				unheld[call] = now
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
+
+//copyHallOrdersMap returns a copy of the hall orders of all elevators that can be sent over the network
+func copyHallOrdersMap() map[int][][utils.ORDER_TYPE_NUM - 1]int {
+	hallOrders := make(map[int][][utils.ORDER_TYPE_NUM - 1]int)
+	for elevatorID, orders := range HallOrdersMap {
+		c := make([][utils.ORDER_TYPE_NUM - 1]int, len(orders.Orders))
+		copy(c, orders.Orders)
+		hallOrders[elevatorID] = c
+	}
+	return hallOrders
+}
+
+//copyTraces returns a copy of the trace IDs of the hall calls that can be sent over the network
+func copyTraces(traces [][utils.ORDER_TYPE_NUM - 1]string) [][utils.ORDER_TYPE_NUM - 1]string {
+	c := make([][utils.ORDER_TYPE_NUM - 1]string, len(traces))
+	copy(c, traces)
+	return c
+}
+
+//copyCabBackups returns a copy of the cab orders of all elevators that can be sent over the network
+func copyCabBackups(cabBackups map[int][]int) map[int][]int {
+	c := make(map[int][]int)
+	for elevatorID, orders := range cabBackups {
+		c[elevatorID] = append([]int(nil), orders...)
+	}
+	return c
+}
+
+//mergeRejoinState takes in the state of an elevator that was not connected to this elevator, because one of them
+//was away or the network was split. The elevators that were on its side know best what happened to them, so its
+//view of them replaces ours, while the view of the elevators on our side is kept. Orders it has assigned to us
+//that we did not know about are returned with their trace IDs so they can be assigned to this elevator, and it
+//takes the orders we have assigned to it in the same way. When both sides kept serving calls, the merge is the
+//same on every elevator: a call given out on both sides is kept by the elevator with the lowest ID, and calls
+//completed on the other side are dropped, as the hall request table has already been merged. Our own orders that
+//are dropped are returned to be revoked. Copies of the cab orders of elevators that were on neither side are
+//merged, so no cab order is lost.
+func mergeRejoinState(evt RejoinStateEvent, table *hallRequestTable, connected map[int]bool, cabBackups map[int][]int) ([]AssignedEvent, []OrderRevokedEvent) {
+	otherSide := make(map[int]bool)
+	for _, elevatorID := range evt.Members {
+		otherSide[elevatorID] = true
+	}
+	own := hallOrdersOf(utils.ELEVATOR_ID)
+	// our own orders after the merge, the controller is told about the changes
+	ownOrders := make([][utils.ORDER_TYPE_NUM - 1]int, utils.FLOOR_NUM)
+	copy(ownOrders, own.Orders)
+	ordersOf := func(elevatorID int) [][utils.ORDER_TYPE_NUM - 1]int {
+		if elevatorID == utils.ELEVATOR_ID {
+			return ownOrders
+		}
+		return hallOrdersOf(elevatorID).Orders
+	}
+	taken, completed, duplicates, cabOrders := 0, 0, 0, 0
+
+	for elevatorID, orders := range evt.HallOrders {
+		if len(orders) != utils.FLOOR_NUM || (elevatorID != utils.ELEVATOR_ID && !otherSide[elevatorID]) {
+			continue
+		}
+		current := ordersOf(elevatorID)
+		for floor := range orders {
+			for orderType, v := range orders[floor] {
+				// the two elevators both end up with the orders either of them had for them
+				if (elevatorID == utils.ELEVATOR_ID || elevatorID == evt.ElevatorID) && v == 0 {
+					continue
+				}
+				if current[floor][orderType] != v {
+					current[floor][orderType] = v
+					taken++
+				}
+			}
+		}
+	}
+
+	if len(evt.Counters) == utils.FLOOR_NUM {
+		for elevatorID := 0; elevatorID < utils.ELEVATOR_MAX_NUM; elevatorID++ {
+			if _, exist := HallOrdersMap[elevatorID]; !exist {
+				continue
+			}
+			orders := ordersOf(elevatorID)
+			for floor := range orders {
+				for orderType := range orders[floor] {
+					if orders[floor][orderType] == 1 && !table.outstanding(floor, OrderType(orderType)) {
+						orders[floor][orderType] = 0
+						completed++
+					}
+				}
+			}
+		}
+	}
+
+	for floor := 0; floor < utils.FLOOR_NUM; floor++ {
+		for orderType := 0; orderType < utils.ORDER_TYPE_NUM-1; orderType++ {
+			var holders []int
+			onOtherSide, onOurSide := false, false
+			for elevatorID := 0; elevatorID < utils.ELEVATOR_MAX_NUM; elevatorID++ {
+				if _, exist := HallOrdersMap[elevatorID]; exist && ordersOf(elevatorID)[floor][orderType] == 1 {
+					holders = append(holders, elevatorID)
+					onOtherSide = onOtherSide || otherSide[elevatorID]
+					onOurSide = onOurSide || !otherSide[elevatorID]
+				}
+			}
+			// calls given to more than one elevator on the same side are left alone, like before the merge
+			if !onOtherSide || !onOurSide {
+				continue
+			}
+			for _, elevatorID := range holders[1:] {
+				ordersOf(elevatorID)[floor][orderType] = 0
+				duplicates++
+			}
+		}
+	}
+
+	for elevatorID, orders := range evt.CabBackups {
+		ours, exist := cabBackups[elevatorID]
+		if elevatorID == utils.ELEVATOR_ID || len(orders) != utils.FLOOR_NUM || (connected[elevatorID] && !otherSide[elevatorID]) {
+			continue
+		}
+		if otherSide[elevatorID] || !exist {
+			if !exist || !reflect.DeepEqual(ours, orders) {
+				cabBackups[elevatorID] = append([]int(nil), orders...)
+				cabOrders++
+			}
+			continue
+		}
+		for floor, order := range orders {
+			if order == 1 && ours[floor] == 0 {
+				ours[floor] = 1
+				cabOrders++
+			}
+		}
+	}
+
+	var missed []AssignedEvent
+	var revoked []OrderRevokedEvent
+	for floor := range ownOrders {
+		for orderType := range ownOrders[floor] {
+			if ownOrders[floor][orderType] == 1 && own.Orders[floor][orderType] == 0 {
+				traceID := ""
+				if len(evt.TraceIDs) == utils.FLOOR_NUM {
+					traceID = evt.TraceIDs[floor][orderType]
+				}
+				missed = append(missed, AssignedEvent{utils.ELEVATOR_ID, -1, floor, OrderType(orderType), false, traceID})
+			} else if ownOrders[floor][orderType] == 0 && own.Orders[floor][orderType] == 1 {
+				revoked = append(revoked, OrderRevokedEvent{utils.ELEVATOR_ID, floor, OrderType(orderType)})
+			}
+		}
+	}
+
+	log.PrintDbg("Merged hall orders from elev", evt.ElevatorID, ", missed orders:", len(missed))
+	if taken+completed+duplicates+cabOrders > 0 {
+		log.PrintInf("Reconciled with elevator", evt.ElevatorID, "of partition epoch", evt.Epoch, "with elevators", evt.Members, ":",
+			taken, "hall orders taken from its side,", completed, "completed on its side,", duplicates, "given out on both sides,",
+			cabOrders, "cab orders backed up")
+		partitionReconciled.Add(float64(taken), "hall_order")
+		partitionReconciled.Add(float64(completed), "completed")
+		partitionReconciled.Add(float64(duplicates), "duplicate")
+		partitionReconciled.Add(float64(cabOrders), "cab_backup")
+	}
+	return missed, revoked
+}
+
 //Deletes all HallOrders from an elevator if its gets unavailable/disconnected
 func deleteAllHallOrders(elevatorID int) {
+	elevatorOrders := hallOrdersOf(elevatorID)
 	for floor := 0; floor < utils.FLOOR_NUM; floor++ {
 		for orderType := 0; orderType < utils.ORDER_TYPE_NUM-1; orderType++ {
-			elevatorOrders := HallOrdersMap[elevatorID]
 			elevatorOrders.Orders[floor][orderType] = 0
-			HallOrdersMap[elevatorID] = elevatorOrders
 		}
 	}
 }
@@ -70,19 +449,17 @@ func deleteAllHallOrders(elevatorID int) {
 //Adds Hall orders for each elevator in HallOrderMap
 func AddHallOrders(assignedEvent AssignedEvent) {
 	log.PrintDbg("Assigned elev to add", assignedEvent.ElevatorID)
-	ActiveOrders := HallOrdersMap[assignedEvent.ElevatorID]
+	ActiveOrders := hallOrdersOf(assignedEvent.ElevatorID)
 	ActiveOrders.Orders[assignedEvent.Floor][assignedEvent.OrderType] = 1
-	HallOrdersMap[assignedEvent.ElevatorID] = ActiveOrders
 	log.PrintDbg("Assigned hall orders for elev", utils.ELEVATOR_ID, " is HallOrdersMap[utils.ELEVATOR_ID]")
 }
 
 //Removes completed orders for each elevator in HallOrderMap
 func RemoveHallOrders(ElevatorID int, floor int) {
-	ActiveOrders := HallOrdersMap[ElevatorID]
+	ActiveOrders := hallOrdersOf(ElevatorID)
 	for i := 0; i < utils.ORDER_TYPE_NUM-1; i++ {
 		ActiveOrders.Orders[floor][i] = 0
 	}
-	HallOrdersMap[ElevatorID] = ActiveOrders
 }
 func RemoveFloorHallOrders(floor int) {
 	for i := 0; i < utils.ELEVATOR_MAX_NUM; i++ {
@@ -1,6 +1,7 @@
 package elevator
 
 import (
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,7 +14,8 @@ import (
 type Order struct {
 	ID       int
 	elevsReg []bool
-	costs    []int
+	results  []CostResultEvent
+	traceID  string
 }
 
 //Struct to be used by AssignedElevator map, contain each chosen ElevID from all elevators
@@ -33,10 +35,21 @@ var elevatorStatus []bool
 var AssignedElevators map[int]AssignedElevatorIDs
 
 //Assigner Module function recieves CostResultEvents from all elevators, Assignes the order
-//to the Elev with lowest cost and send the order to Controller if all
-//Elevators returns the same assigned elev
+//to the Elev chosen by the assignment strategy and send the order to Controller if all
+//Elevators returns the same assigned elev.
+//The hall orders of a lost elevator are given out again by the leader elected by the election module.
+//In the optimal mode the costs are not used, the leader instead assigns all outstanding hall calls
+//every Assignment.Interval, and when a new call is pressed.
+//In the bidding mode the leader hands assigned hall orders over to an elevator that would
+//serve them sooner every Assignment.ReassignInterval.
 func AssignerModule() {
-	log.PrintInf("Started")
+	optimal := utils.Config.Assignment.Mode == utils.ModeOptimal
+	reassign := !optimal && utils.Config.Assignment.ReassignInterval.Duration > 0
+	if optimal {
+		log.PrintInf("Started in optimal assignment mode")
+	} else {
+		log.PrintInf("Started with assignment strategy", utils.Config.Assignment.Strategy)
+	}
 
 	assignedPub := make(chan AssignedEvent)
 	activeOrdersReqPub := make(chan ActiveOrdersReqEvent)
@@ -44,6 +57,12 @@ func AssignerModule() {
 	unavailableOrdersHandledPub := make(chan UnavailableOrdersHandledEvent)
 	checkAssignedElevPub := make(chan CheckAssignedElevEvent)
 	assignedOKPub := make(chan AssignedOKEvent)
+	hallAssignmentPub := make(chan HallAssignmentEvent)
+	orderRevokedPub := make(chan OrderRevokedEvent)
+	handoverProposePub := make(chan HandoverProposeEvent)
+	handoverAnswerPub := make(chan HandoverAnswerEvent)
+	handoverCommitPub := make(chan HandoverCommitEvent)
+	lostOrdersHandledPub := make(chan LostOrdersHandledEvent)
 
 	costResultSub := make(chan CostResultEvent)
 	availabilitySub := make(chan AvailabilityEvent)
@@ -51,31 +70,137 @@ func AssignerModule() {
 	activeOrdersAnsSub := make(chan ActiveOrdersAnsEvent)
 	assignedOKSub := make(chan AssignedOKEvent)
 	checkAssignedElevSub := make(chan CheckAssignedElevEvent)
+	rejoinStateSub := make(chan RejoinStateEvent)
+	rejoinDoneSub := make(chan RejoinDoneEvent)
+	leaderChangedSub := make(chan LeaderChangedEvent)
+	lostOrdersHandledSub := make(chan LostOrdersHandledEvent)
 
-	eventManager.AddPublishers(assignedPub, newOrderPub, activeOrdersReqPub, unavailableOrdersHandledPub, checkAssignedElevPub, assignedOKPub)
-	eventManager.AddSubscribers(costResultSub, availabilitySub, activeOrdersAnsSub, connectSub, assignedOKSub, checkAssignedElevSub)
+	eventManager.AddPublishers(assignedPub, newOrderPub, activeOrdersReqPub, unavailableOrdersHandledPub, checkAssignedElevPub, assignedOKPub,
+		hallAssignmentPub, orderRevokedPub, handoverProposePub, handoverAnswerPub, handoverCommitPub, lostOrdersHandledPub)
+	eventManager.AddSubscribers(costResultSub, availabilitySub, activeOrdersAnsSub, connectSub, assignedOKSub, checkAssignedElevSub,
+		rejoinStateSub, rejoinDoneSub, leaderChangedSub, lostOrdersHandledSub)
+
+	//The events of the optimal mode and of handovers are only subscribed to when used, the channels are nil otherwise
+	var elevatorStateSub chan ElevatorStateEvent
+	var hallRequestTableSub chan HallRequestTableEvent
+	var newOrderSub chan NewOrderEvent
+	var hallAssignmentSub chan HallAssignmentEvent
+	var roundTicker <-chan time.Time
+	var handoverProposeSub chan HandoverProposeEvent
+	var handoverAnswerSub chan HandoverAnswerEvent
+	var reassignTicker <-chan time.Time
+	if optimal || reassign {
+		elevatorStateSub = make(chan ElevatorStateEvent)
+		eventManager.AddSubscribers(elevatorStateSub)
+	}
+	if optimal {
+		hallRequestTableSub = make(chan HallRequestTableEvent)
+		newOrderSub = make(chan NewOrderEvent)
+		hallAssignmentSub = make(chan HallAssignmentEvent)
+		eventManager.AddSubscribers(hallRequestTableSub, newOrderSub, hallAssignmentSub)
+		roundTicker = time.NewTicker(utils.Config.Assignment.Interval.Duration).C
+	}
+	if reassign {
+		handoverProposeSub = make(chan HandoverProposeEvent)
+		handoverAnswerSub = make(chan HandoverAnswerEvent)
+		eventManager.AddSubscribers(handoverProposeSub, handoverAnswerSub)
+		reassignTicker = time.NewTicker(utils.Config.Assignment.ReassignInterval.Duration).C
+	}
+	//last state received from each elevator
+	states := make(map[int]ElevatorStateEvent)
+	optimalState := newOptimalAssigner()
+	handoverState := newHandoverCoordinator()
+	handoverExpiredCh := make(chan int)
+	leader := -1
+	//lost elevators whose hall orders the leader may not have given out, as there was no leader or the leader was
+	//lost too. They are given out if this elevator becomes the leader before they are back.
+	lost := make(map[int]bool)
+	//assignRound starts an optimal assignment round if this elevator is the leader
+	assignRound := func() {
+		activeElevs := ReturnActiveElevatorsID()
+		if leader == utils.ELEVATOR_ID && len(activeElevs) != 0 {
+			hallAssignmentPub <- optimalState.newRound(activeElevs, states)
+		}
+	}
+	//giveOutOrders gives the hall orders of a lost elevator to the others if this elevator is the leader
+	giveOutOrders := func(elevatorID int) {
+		if leader != utils.ELEVATOR_ID {
+			lost[elevatorID] = true
+			return
+		}
+		activeOrdersReqPub <- ActiveOrdersReqEvent{elevatorID}
+	}
+	//forgetOrders revokes the hall orders given to an elevator that is gone, and gives them out again
+	forgetOrders := func(elevatorID int) {
+		for _, revoked := range optimalState.forget(elevatorID) {
+			orderRevokedPub <- revoked
+		}
+		assignRound()
+	}
 
 	i := 0
-	go distributeOrders(&i, activeOrdersAnsSub, newOrderPub, unavailableOrdersHandledPub)
+	go distributeOrders(&i, activeOrdersAnsSub, newOrderPub, unavailableOrdersHandledPub, lostOrdersHandledPub)
 
 	elevatorStatus = make([]bool, utils.ELEVATOR_MAX_NUM)
 	elevatorStatus[utils.ELEVATOR_ID] = true
 	orderMap = make(map[int]Order)
 	AssignedElevators = make(map[int]AssignedElevatorIDs)
 	mtx := &sync.Mutex{}
+	strategy := newAssignmentStrategy(utils.Config.Assignment)
+
+	// Connected elevators are not counted in order assignment before they have received our state,
+	// rejoining holds the elevators we are waiting for and the availability they reported
+	rejoining := make(map[int]bool)
+	rejoinTimeoutCh := make(chan int)
+
+	//The results of each order are routed to the check of that order. Results can arrive before our own
+	//check is started, they are then kept until it starts, or thrown away after a while
+	checks := make(map[int]chan CheckAssignedElevEvent)
+	checking := make(map[int]bool)
+	checkDoneCh := make(chan int)
+	checkExpiredCh := make(chan int)
+	checkFor := func(orderID int) chan CheckAssignedElevEvent {
+		ch, exist := checks[orderID]
+		if !exist {
+			ch = make(chan CheckAssignedElevEvent, utils.ELEVATOR_MAX_NUM)
+			checks[orderID] = ch
+			time.AfterFunc(2*utils.Config.Elevator.MaxDecideTime.Duration, func() { checkExpiredCh <- orderID })
+		}
+		return ch
+	}
 	for {
 		select {
 		case evt := <-costResultSub:
-			readyToServe := registerOrder(evt.ElevatorID, evt.OrderID, evt.Score)
+			if optimal {
+				break
+			}
+			readyToServe := registerOrder(evt)
 			var elevToServe int
 			if readyToServe {
 				order := orderMap[evt.OrderID]
-				elevToServe = assignElevToServeOrder(order.costs)
-				checkAssignedElev := CheckAssignedElevEvent{utils.ELEVATOR_ID, elevToServe, order.ID, evt.Floor, evt.OrderType}
+				elevToServe = strategy.Assign(order.results, ReturnActiveElevatorsID())
+				checkAssignedElev := CheckAssignedElevEvent{utils.ELEVATOR_ID, elevToServe, order.ID, evt.Floor, evt.OrderType, order.traceID}
 				if elevatorStatus[utils.ELEVATOR_ID] {
 					checkAssignedElevPub <- checkAssignedElev
 				}
-				go checkForSameResult(checkAssignedElevSub, assignedOKPub, checkAssignedElev, mtx)
+				checking[order.ID] = true
+				go func(results chan CheckAssignedElevEvent, orderID int) {
+					checkForSameResult(results, assignedOKPub, checkAssignedElev, mtx)
+					checkDoneCh <- orderID
+				}(checkFor(order.ID), order.ID)
+			}
+		case evt := <-checkAssignedElevSub:
+			select {
+			case checkFor(evt.OrderID) <- evt:
+			default:
+				log.PrintErr("Dropped assignment result for order", evt.OrderID)
+			}
+		case orderID := <-checkDoneCh:
+			delete(checks, orderID)
+			delete(checking, orderID)
+		case orderID := <-checkExpiredCh:
+			if !checking[orderID] {
+				delete(checks, orderID)
 			}
 		case evt := <-assignedOKSub:
 			singleMode := false
@@ -83,37 +208,139 @@ func AssignerModule() {
 				singleMode = true
 			}
 			if evt.SameAssigned {
-				assignedEvent := AssignedEvent{evt.AssignedElevatorID, evt.OrderID, evt.Floor, evt.OrderType, singleMode}
+				assignedEvent := AssignedEvent{evt.AssignedElevatorID, evt.OrderID, evt.Floor, evt.OrderType, singleMode, evt.TraceID}
 				assignedPub <- assignedEvent
 				delete(orderMap, evt.OrderID)
 			} else {
 				ActiveElevs := ReturnActiveElevatorsID()
 				for _, v := range ActiveElevs {
-					assignedEvent := AssignedEvent{v, evt.OrderID, evt.Floor, evt.OrderType, singleMode}
+					assignedEvent := AssignedEvent{v, evt.OrderID, evt.Floor, evt.OrderType, singleMode, evt.TraceID}
 					assignedPub <- assignedEvent
 					delete(orderMap, evt.OrderID)
 				}
 			}
 		case evt := <-availabilitySub:
+			if _, waiting := rejoining[evt.ElevatorID]; waiting {
+				rejoining[evt.ElevatorID] = evt.Availabable
+				break
+			}
+			if optimal {
+				elevatorStatus[evt.ElevatorID] = evt.Availabable
+				if !evt.Availabable {
+					forgetOrders(evt.ElevatorID)
+				}
+				break
+			}
 			if !singleElevatorAvailable() {
 				elevatorStatus[evt.ElevatorID] = evt.Availabable
-				if (evt.ElevatorID != utils.ELEVATOR_ID) && !evt.Availabable {
-					ActiveElevatorID := ReturnActiveElevatorsID()
-					if len(ActiveElevatorID) != 0 && ActiveElevatorID[0] == utils.ELEVATOR_ID {
-						activeOrdersReqPub <- ActiveOrdersReqEvent{evt.ElevatorID}
-					}
+				if !evt.Availabable {
+					giveOutOrders(evt.ElevatorID)
 				}
 			}
+			if evt.Availabable {
+				delete(lost, evt.ElevatorID)
+			}
 			if evt.ElevatorID != utils.ELEVATOR_ID {
 				elevatorStatus[evt.ElevatorID] = evt.Availabable
 			}
 		case evt := <-connectSub:
-			elevatorStatus[evt.ElevatorID] = evt.Connect
-			if !evt.Connect {
-				ActiveElevatorID := ReturnActiveElevatorsID()
-				if len(ActiveElevatorID) != 0 && ActiveElevatorID[0] == utils.ELEVATOR_ID {
-					activeOrdersReqPub <- ActiveOrdersReqEvent{evt.ElevatorID}
-				}
+			if evt.Connect {
+				rejoining[evt.ElevatorID] = true
+				id := evt.ElevatorID
+				time.AfterFunc(utils.Config.Network.RejoinTimeout.Duration, func() { rejoinTimeoutCh <- id })
+				break
+			}
+			delete(rejoining, evt.ElevatorID)
+			elevatorStatus[evt.ElevatorID] = false
+			if optimal {
+				forgetOrders(evt.ElevatorID)
+				break
+			}
+			giveOutOrders(evt.ElevatorID)
+		case evt := <-rejoinStateSub:
+			if _, waiting := rejoining[evt.ElevatorID]; waiting && evt.TargetID == utils.ELEVATOR_ID {
+				rejoining[evt.ElevatorID] = evt.Available
+			}
+		case evt := <-rejoinDoneSub:
+			if available, waiting := rejoining[evt.ElevatorID]; waiting && evt.TargetID == utils.ELEVATOR_ID {
+				log.PrintDbg("Elevator", evt.ElevatorID, "has rejoined, available:", available)
+				elevatorStatus[evt.ElevatorID] = available
+				delete(rejoining, evt.ElevatorID)
+				delete(lost, evt.ElevatorID)
+			}
+		case evt := <-elevatorStateSub:
+			states[evt.ElevatorID] = evt
+		case evt := <-newOrderSub:
+			optimalState.observeOrder(evt)
+		case evt := <-hallRequestTableSub:
+			if optimalState.observeTable(evt) {
+				assignRound()
+			}
+		case <-roundTicker:
+			assignRound()
+		case evt := <-hallAssignmentSub:
+			revoked, assigned, err := optimalState.apply(evt)
+			if err != nil {
+				assignmentRoundsRejected.Inc()
+				log.PrintErr("Rejected assignment round", evt.Round, "from elevator", evt.ElevatorID, ":", err)
+				break
+			}
+			for _, r := range revoked {
+				orderRevokedPub <- r
+			}
+			for _, a := range assigned {
+				ordersAssigned.Inc(strconv.Itoa(a.ElevatorID))
+				assignedPub <- a
+			}
+		case <-reassignTicker:
+			activeElevs := ReturnActiveElevatorsID()
+			if leader != utils.ELEVATOR_ID || len(activeElevs) == 0 {
+				break
+			}
+			for _, p := range handoverState.propose(activeElevs, states) {
+				log.PrintInf("Proposing handover of hall order on floor", p.Floor, "type", p.OrderType, "from elevator", p.From, "to", p.To)
+				handoverProposePub <- p
+				id := p.HandoverID
+				time.AfterFunc(2*utils.Config.Elevator.MaxDecideTime.Duration, func() { handoverExpiredCh <- id })
+			}
+		case evt := <-handoverProposeSub:
+			if evt.From == utils.ELEVATOR_ID || evt.To == utils.ELEVATOR_ID {
+				own, known := states[utils.ELEVATOR_ID]
+				handoverAnswerPub <- HandoverAnswerEvent{utils.ELEVATOR_ID, evt.HandoverID, handoverAnswer(evt, own, known)}
+			}
+		case evt := <-handoverAnswerSub:
+			commit, committed, done := handoverState.answer(evt)
+			if done && !committed {
+				log.PrintInf("Handover", evt.HandoverID, "rejected by elevator", evt.ElevatorID)
+			}
+			if committed {
+				handoverCommitPub <- commit
+			}
+		case evt := <-lostOrdersHandledSub:
+			if evt.ElevatorID != utils.ELEVATOR_ID {
+				delete(lost, evt.LostID)
+			}
+		case evt := <-leaderChangedSub:
+			leader = evt.LeaderID
+			if leader != utils.ELEVATOR_ID {
+				break
+			}
+			if optimal {
+				assignRound()
+				break
+			}
+			for id := range lost {
+				log.PrintInf("Giving out the hall orders of elevator", id, "as the new leader")
+				activeOrdersReqPub <- ActiveOrdersReqEvent{id}
+				delete(lost, id)
+			}
+		case id := <-handoverExpiredCh:
+			handoverState.expire(id)
+		case id := <-rejoinTimeoutCh:
+			if available, waiting := rejoining[id]; waiting {
+				log.PrintErr("No rejoin answer from elevator", id, ", counting it in assignment anyway")
+				elevatorStatus[id] = available
+				delete(rejoining, id)
 			}
 		}
 	}
@@ -135,38 +362,46 @@ func generateOrderID(i *int) int {
 }
 
 //Generates and publishes new orders whenever a new active order set is received on the subscribed event channel
-func distributeOrders(i *int, activeOrdersAnsSub chan ActiveOrdersAnsEvent, newOrderPub chan NewOrderEvent, unavailableOrdersHandledPub chan UnavailableOrdersHandledEvent) {
+func distributeOrders(i *int, activeOrdersAnsSub chan ActiveOrdersAnsEvent, newOrderPub chan NewOrderEvent, unavailableOrdersHandledPub chan UnavailableOrdersHandledEvent,
+	lostOrdersHandledPub chan LostOrdersHandledEvent) {
 	for evt := range activeOrdersAnsSub {
 		for floor := 0; floor < utils.FLOOR_NUM; floor++ {
 			for orderType, v := range evt.ActiveOrders[floor] {
 				if v == 1 {
 					orderID := generateOrderID(i)
-					NewOrder := NewOrderEvent{utils.ELEVATOR_ID, floor, orderID, OrderType(orderType)}
+					// an order without a trace is given out without one, a new trace would not be the order's own
+					// and would not be the same in a replay
+					traceID := ""
+					if len(evt.TraceIDs) == utils.FLOOR_NUM {
+						traceID = evt.TraceIDs[floor][orderType]
+					}
+					NewOrder := NewOrderEvent{utils.ELEVATOR_ID, floor, orderID, OrderType(orderType), traceID}
 					newOrderPub <- NewOrder
 				}
 			}
 		}
 		Handled := UnavailableOrdersHandledEvent{evt.ElevatorID, true}
 		unavailableOrdersHandledPub <- Handled
+		lostOrdersHandledPub <- LostOrdersHandledEvent{utils.ELEVATOR_ID, evt.ElevatorID}
 	}
 }
 
 //Registrers a new order in the global orderMap variable, with order ID as key. Returns wheather the order is ready to serveor not
-func registerOrder(elevatorID int, orderID int, cost int) bool {
+func registerOrder(result CostResultEvent) bool {
 	var readyToServe bool
-	_, exist := orderMap[orderID]
+	_, exist := orderMap[result.OrderID]
 	var newOrder Order
 	if exist {
-		newOrder = orderMap[orderID]
-		newOrder.elevsReg[elevatorID] = true
-		newOrder.costs[elevatorID] = cost
+		newOrder = orderMap[result.OrderID]
+		newOrder.elevsReg[result.ElevatorID] = true
+		newOrder.results[result.ElevatorID] = result
 
 	} else {
-		costs := make([]int, utils.ELEVATOR_MAX_NUM)
+		results := make([]CostResultEvent, utils.ELEVATOR_MAX_NUM)
 		elevsReg := make([]bool, utils.ELEVATOR_MAX_NUM)
-		elevsReg[elevatorID] = true
-		costs[elevatorID] = cost
-		newOrder = Order{orderID, elevsReg, costs}
+		elevsReg[result.ElevatorID] = true
+		results[result.ElevatorID] = result
+		newOrder = Order{result.OrderID, elevsReg, results, result.TraceID}
 	}
 	for i, v := range newOrder.elevsReg {
 		if v == elevatorStatus[i] {
@@ -176,24 +411,10 @@ func registerOrder(elevatorID int, orderID int, cost int) bool {
 			break
 		}
 	}
-	orderMap[orderID] = newOrder
+	orderMap[result.OrderID] = newOrder
 	return readyToServe
 }
 
-//Finds elevator with the lowest cost and assignes it to that elevator. Prioritizes smalles elevator ID
-func assignElevToServeOrder(cost []int) int {
-	activeElevatorsID := ReturnActiveElevatorsID()
-	elevId := activeElevatorsID[0]
-	costVal := cost[elevId]
-	for _, v := range activeElevatorsID {
-		if cost[v] < costVal {
-			costVal = cost[v]
-			elevId = v
-		}
-	}
-	return elevId
-}
-
 func ReturnActiveElevatorsID() []int {
 	var activeElevatorsID []int
 	for i, v := range elevatorStatus {
@@ -207,16 +428,16 @@ func ReturnActiveElevatorsID() []int {
 //Run as goroutine to compare the assigned elevators from each of the active elevator
 //Returns true/false if AssignedElevIDs are/are not the same
 //and false if timer runs out.
-func checkForSameResult(CheckAssignedElevSub chan CheckAssignedElevEvent, assignedOKPub chan AssignedOKEvent, assigned CheckAssignedElevEvent, mtx *sync.Mutex) {
+func checkForSameResult(CheckAssignedElevSub <-chan CheckAssignedElevEvent, assignedOKPub chan AssignedOKEvent, assigned CheckAssignedElevEvent, mtx *sync.Mutex) {
 
-	AssignedTimer := time.NewTimer(utils.MAX_DECIDE_TIME * time.Millisecond)
+	AssignedTimer := time.NewTimer(utils.Config.Elevator.MaxDecideTime.Duration)
 
 	for {
 		select {
 		case evt := <-CheckAssignedElevSub:
 			if SameAssignedElevs(evt, mtx) {
 				AssignedTimer.Stop()
-				AssignedOK := AssignedOKEvent{true, evt.AssignedElevatorID, evt.OrderID, evt.Floor, evt.OrderType}
+				AssignedOK := AssignedOKEvent{true, evt.AssignedElevatorID, evt.OrderID, evt.Floor, evt.OrderType, evt.TraceID}
 				assignedOKPub <- AssignedOK
 				mtx.Lock()
 				delete(AssignedElevators, evt.OrderID)
@@ -235,7 +456,7 @@ func checkForSameResult(CheckAssignedElevSub chan CheckAssignedElevEvent, assign
 					}
 				}
 				if ElevsRegTrue == NumActiveElevators {
-					AssignedOK := AssignedOKEvent{false, evt.AssignedElevatorID, evt.OrderID, evt.Floor, evt.OrderType}
+					AssignedOK := AssignedOKEvent{false, evt.AssignedElevatorID, evt.OrderID, evt.Floor, evt.OrderType, evt.TraceID}
 					assignedOKPub <- AssignedOK
 					mtx.Lock()
 					delete(AssignedElevators, evt.OrderID)
@@ -245,7 +466,8 @@ func checkForSameResult(CheckAssignedElevSub chan CheckAssignedElevEvent, assign
 				}
 			}
 		case <-AssignedTimer.C:
-			AssignedOK := AssignedOKEvent{false, assigned.AssignedElevatorID, assigned.OrderID, assigned.Floor, assigned.OrderType}
+			assignmentTimeouts.Inc()
+			AssignedOK := AssignedOKEvent{false, assigned.AssignedElevatorID, assigned.OrderID, assigned.Floor, assigned.OrderType, assigned.TraceID}
 			assignedOKPub <- AssignedOK
 			mtx.Lock()
 			delete(AssignedElevators, assigned.OrderID)
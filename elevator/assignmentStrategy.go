@@ -0,0 +1,97 @@
+package elevator
+
+import (
+	"./utils"
+)
+
+//AssignmentStrategy chooses the elevator to serve a hall order. Every elevator runs the strategy on the cost results
+//of all elevators, and the order is only assigned if they all choose the same elevator. All elevators must therefore
+//use the same strategy, and it must choose the same elevator from the same results on every elevator.
+type AssignmentStrategy interface {
+	//Assign returns the elevator to serve the order. results holds the cost result of each elevator by ID, active
+	//the IDs of the available elevators, in increasing order and never empty. Only active elevators may be chosen.
+	Assign(results []CostResultEvent, active []int) int
+}
+
+//newAssignmentStrategy returns the strategy set in the config file
+func newAssignmentStrategy(settings utils.AssignmentSettings) AssignmentStrategy {
+	switch settings.Strategy {
+	case utils.StrategyRoundRobin:
+		return roundRobin{}
+	case utils.StrategyNearestCar:
+		return nearestCar{}
+	case utils.StrategyLoadBalanced:
+		return loadBalanced{}
+	case utils.StrategyZoned:
+		return zoned{settings.Zones}
+	}
+	return lowestCost{}
+}
+
+//lowestCost chooses the elevator that can serve the order the fastest, ties to the lowest ID
+type lowestCost struct{}
+
+func (lowestCost) Assign(results []CostResultEvent, active []int) int {
+	return lowestBy(results, active, func(r CostResultEvent) int { return r.Score })
+}
+
+//roundRobin gives the orders to the active elevators in turn. The turn is taken from the order ID, which is the same
+//on all elevators, so they choose the same without sharing any state. Orders from the same panel are spread out.
+type roundRobin struct{}
+
+func (roundRobin) Assign(results []CostResultEvent, active []int) int {
+	return active[results[active[0]].OrderID%len(active)]
+}
+
+//nearestCar chooses the elevator closest to the floor of the order, ties to the lowest cost
+type nearestCar struct{}
+
+func (nearestCar) Assign(results []CostResultEvent, active []int) int {
+	return lowestBy(results, active, func(r CostResultEvent) int {
+		distance := r.ElevatorFloor - r.Floor
+		if distance < 0 {
+			distance = -distance
+		}
+		// the cost is far less than a floor apart, so it only decides between elevators at the same distance
+		return distance*1000000 + r.Score
+	})
+}
+
+//loadBalanced chooses the elevator with the fewest orders, ties to the lowest cost
+type loadBalanced struct{}
+
+func (loadBalanced) Assign(results []CostResultEvent, active []int) int {
+	return lowestBy(results, active, func(r CostResultEvent) int { return r.QueueLength*1000000 + r.Score })
+}
+
+//zoned chooses the elevator with the lowest cost among the elevators whose zone has the floor of the order.
+//Elevators without a zone serve all floors. If no active elevator serves the floor, any active elevator may.
+type zoned struct {
+	zones map[int][2]int
+}
+
+func (z zoned) Assign(results []CostResultEvent, active []int) int {
+	floor := results[active[0]].Floor
+	var inZone []int
+	for _, id := range active {
+		zone, exist := z.zones[id]
+		if !exist || (floor >= zone[0] && floor <= zone[1]) {
+			inZone = append(inZone, id)
+		}
+	}
+	if len(inZone) == 0 {
+		inZone = active
+	}
+	return lowestCost{}.Assign(results, inZone)
+}
+
+//lowestBy returns the active elevator with the lowest value, ties to the lowest ID
+func lowestBy(results []CostResultEvent, active []int, value func(CostResultEvent) int) int {
+	best := active[0]
+	for _, id := range active[1:] {
+		if value(results[id]) < value(results[best]) {
+			best = id
+		}
+	}
+	return best
+}
@@ -0,0 +1,168 @@
+package elevator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"./log"
+	"./utils"
+)
+
+//Version of the cab order backup format. Files without a version are the old format with one int per line.
+const cabBackupVersion = 1
+
+//cabBackup is the on-disk format of the cab order backup
+type cabBackup struct {
+	Version   int
+	Floors    int
+	CabOrders []int
+	// Checksum is the CRC32 of the fields above, see cabBackupChecksum
+	Checksum uint32
+}
+
+func cabBackupChecksum(version int, floors int, orders []int) uint32 {
+	return crc32.ChecksumIEEE([]byte(fmt.Sprintf("%d:%d:%v", version, floors, orders)))
+}
+
+//saveCabBackup writes the cab orders to a temp file and renames it over the backup, so a crash leaves either
+//the old or the new backup on disk, never a partial one
+func saveCabBackup(filename string, orders []int) error {
+	data, err := json.Marshal(cabBackup{
+		Version:   cabBackupVersion,
+		Floors:    len(orders),
+		CabOrders: orders,
+		Checksum:  cabBackupChecksum(cabBackupVersion, len(orders), orders),
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpName := filename + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
+
+//syncDir makes the rename of a file in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+//loadCabBackup reads the cab orders from the backup. A missing backup gives no orders. A corrupt backup is
+//moved to <filename>.corrupt and gives no orders, the cab orders are then only restored from the other elevators.
+//The returned slice always has one entry per floor.
+func loadCabBackup(filename string) []int {
+	orders := make([]int, utils.FLOOR_NUM)
+	// left behind if we crashed while saving, the backup itself is still intact
+	os.Remove(filename + ".tmp")
+
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return orders
+	}
+	if err != nil {
+		log.PrintErr("Could not read cab order backup:", err)
+		return orders
+	}
+
+	stored, err := parseCabBackup(raw)
+	if err != nil {
+		log.PrintErr("Cab order backup", filename, "is corrupt:", err)
+		if err := os.Rename(filename, filename+".corrupt"); err != nil {
+			log.PrintErr("Could not move corrupt cab order backup:", err)
+		}
+		return orders
+	}
+	if len(stored) != utils.FLOOR_NUM {
+		log.PrintErr("Cab order backup has", len(stored), "floors, the building has", utils.FLOOR_NUM)
+	}
+	copy(orders, stored)
+	return orders
+}
+
+//parseCabBackup parses the current format, or the old format with one int per line.
+//An empty file has no cab orders, as the old code created the file empty at startup.
+func parseCabBackup(raw []byte) ([]int, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 {
+		return make([]int, utils.FLOOR_NUM), nil
+	}
+	if trimmed[0] != '{' {
+		orders, err := parseLegacyCabBackup(trimmed)
+		if err == nil {
+			log.PrintInf("Migrating cab order backup from the old format")
+		}
+		return orders, err
+	}
+
+	var backup cabBackup
+	if err := json.Unmarshal(trimmed, &backup); err != nil {
+		return nil, err
+	}
+	if backup.Version != cabBackupVersion {
+		return nil, fmt.Errorf("unknown version %d", backup.Version)
+	}
+	if backup.Floors != len(backup.CabOrders) {
+		return nil, fmt.Errorf("%d floors but %d orders", backup.Floors, len(backup.CabOrders))
+	}
+	if backup.Checksum != cabBackupChecksum(backup.Version, backup.Floors, backup.CabOrders) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return backup.CabOrders, checkCabOrders(backup.CabOrders)
+}
+
+func parseLegacyCabBackup(raw []byte) ([]int, error) {
+	var orders []int
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		num, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, num)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return orders, checkCabOrders(orders)
+}
+
+func checkCabOrders(orders []int) error {
+	for floor, order := range orders {
+		if order != 0 && order != 1 {
+			return fmt.Errorf("invalid order %d on floor %d", order, floor)
+		}
+	}
+	return nil
+}
@@ -12,25 +12,25 @@ import (
 func DialBroadcastUDP(port int) net.PacketConn {
 	s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
 	if err != nil {
-		fmt.Printf("Error: Socket: %#+v\n")
+		fmt.Printf("Error: Socket: %#+v\n", err)
 	}
-	syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	err = syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
 	if err != nil {
-		fmt.Printf("Error: SetSockOpt REUSEADDR: %#+v\n")
+		fmt.Printf("Error: SetSockOpt REUSEADDR: %#+v\n", err)
 	}
-	syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	err = syscall.SetsockoptInt(s, syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
 	if err != nil {
-		fmt.Printf("Error: SetSockOpt BROADCAST:  %#+v\n")
+		fmt.Printf("Error: SetSockOpt BROADCAST:  %#+v\n", err)
 	}
-	syscall.Bind(s, &syscall.SockaddrInet4{Port: port})
+	err = syscall.Bind(s, &syscall.SockaddrInet4{Port: port})
 	if err != nil {
-		fmt.Printf("Error: Bind:  %#+v\n")
+		fmt.Printf("Error: Bind:  %#+v\n", err)
 	}
 
 	f := os.NewFile(uintptr(s), "")
 	conn, err := net.FilePacketConn(f)
 	if err != nil {
-		fmt.Printf("Error: FilePacketConn: %#+v\n")
+		fmt.Printf("Error: FilePacketConn: %#+v\n", err)
 	}
 	f.Close()
 
@@ -0,0 +1,147 @@
+package elevator
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+
+	"./eventManager"
+	"./log"
+	"./utils"
+)
+
+//controlMux holds the handlers of the control interface. Other modules can add their own handlers to it.
+var controlMux = http.NewServeMux()
+
+//ControlModule serves the control interface on localhost, used to inspect and change the elevator while it is running
+func ControlModule() {
+	if utils.Config.Control.Port == 0 {
+		return
+	}
+	controlMux.HandleFunc("/events", handleEvents)
+	controlMux.HandleFunc("/events/logging", handleEventLogging)
+	controlMux.HandleFunc("/registry", handleRegistry)
+	controlMux.HandleFunc("/log/levels", handleLogLevels)
+
+	//Only reachable from this computer, as anyone reaching it can change the elevator
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(utils.Config.Control.Port+utils.ELEVATOR_ID))
+	log.PrintInf("Control interface on http://" + addr)
+	err := http.ListenAndServe(addr, controlMux)
+	log.PrintErr("Control interface stopped:", err)
+}
+
+//handleEvents lists the event types known to the broker
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	all, _ := eventManager.EventLogging()
+	writeJSON(w, struct {
+		Logging bool
+		Events  []eventManager.EventInfo
+	}{all, eventManager.Events()})
+}
+
+//handleEventLogging turns logging of an event on or off, e.g. POST /events/logging?event=AssignedEvent&on=true.
+//Without event all event logging is turned on or off.
+func handleEventLogging(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodPost {
+		on, err := strconv.ParseBool(r.URL.Query().Get("on"))
+		if err != nil {
+			http.Error(w, "on must be true or false", http.StatusBadRequest)
+			return
+		}
+		event := r.URL.Query().Get("event")
+		if event == "" {
+			event = "Logging"
+		}
+		if err := eventManager.SetEventLogging(event, on); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.PrintInf("Logging of", event, "set to", on)
+	}
+	all, events := eventManager.EventLogging()
+	writeJSON(w, struct {
+		Logging bool
+		Events  map[string]bool
+	}{all, events})
+}
+
+//handleRegistry dumps the publishers and subscriptions of the broker
+func handleRegistry(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, eventManager.Registry())
+}
+
+//handleLogLevels shows or changes the log levels, e.g. POST /log/levels?module=assigner&level=DBG.
+//Without module the level of the modules without a level of their own is changed.
+func handleLogLevels(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodPost {
+		level, err := log.ParseLevel(r.URL.Query().Get("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if module := r.URL.Query().Get("module"); module != "" {
+			log.SetLevel(module, level)
+		} else {
+			log.SetDefaultLevel(level)
+		}
+	}
+	level, modules := log.Levels()
+	levels := make(map[string]string, len(modules))
+	for module, l := range modules {
+		levels[module] = l.String()
+	}
+	writeJSON(w, struct {
+		Level   string
+		Modules map[string]string
+	}{level.String(), levels})
+}
+
+//allowMethods answers 405 Method Not Allowed if the method is not one of methods, and checks the control header
+//of requests that are not GET
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return r.Method == http.MethodGet || checkControlHeader(w, r)
+		}
+	}
+	for _, m := range methods {
+		w.Header().Add("Allow", m)
+	}
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+//Requests that change the elevator must have this header. A web page can not send it to another site without
+//asking first, so a page open in a browser on this computer can not post forms to the control interface.
+const controlHeader = "X-Elevator-Control"
+
+//checkControlHeader answers 403 Forbidden if the request does not have the control header
+func checkControlHeader(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get(controlHeader) == "" {
+		http.Error(w, "requests that change the elevator must have the "+controlHeader+" header", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.PrintErr("Could not write response:", err)
+	}
+}
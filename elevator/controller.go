@@ -1,9 +1,7 @@
 package elevator
 
 import (
-	"bufio"
-	"fmt"
-	"os"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -20,6 +18,7 @@ const (
 	behaviourDoorOpen
 	behaviourMoving
 	behaviourObstructed
+	behaviourStopped
 )
 
 //Type definition elevator movement
@@ -38,12 +37,30 @@ type ElevatorState struct {
 	Behaviour    ElevatorBehaviour
 	Movement     Movement
 	Available    bool
-	ActiveOrders [utils.FLOOR_NUM][utils.ORDER_TYPE_NUM]int
+	ActiveOrders [][utils.ORDER_TYPE_NUM]int
+}
+
+//copyState returns a copy of the state that does not share active orders with the original
+func (state ElevatorState) copyState() ElevatorState {
+	c := state
+	c.ActiveOrders = make([][utils.ORDER_TYPE_NUM]int, len(state.ActiveOrders))
+	copy(c.ActiveOrders, state.ActiveOrders)
+	return c
 }
 
 //Global declaration of the elevator state of this program
 var elevatorState ElevatorState
 
+//Movement and floor status when the stop button was pressed, used to resume when it is released
+var movementBeforeStop Movement
+var stoppedAtFloor bool
+
+//Last obstruction input, also followed while stopped so the door stays open if it is obstructed on release
+var obstructed bool
+
+//outOfService is set by the operator interface. The elevator then stays unavailable until it is put back in service.
+var outOfService bool
+
 //Timers used in the controller module
 var obstructTimer *time.Timer
 var doorTimer *time.Timer
@@ -54,6 +71,7 @@ func ControllerModule() {
 
 	elevatorState.ElevatorID = utils.ELEVATOR_ID
 	elevatorState.Available = true
+	elevatorState.ActiveOrders = make([][utils.ORDER_TYPE_NUM]int, utils.FLOOR_NUM)
 
 	log.PrintInf("Started")
 
@@ -63,6 +81,8 @@ func ControllerModule() {
 	availabilityPub := make(chan AvailabilityEvent)
 	OrderLampCtrPub := make(chan OrderLampCtrEvent)
 	OrderLampsOffCtrPub := make(chan OrderLampsOffCtrEvent)
+	cabOrdersBackupPub := make(chan CabOrdersBackupEvent)
+	elevatorStatePub := make(chan ElevatorStateEvent)
 
 	orderCompleteSub := make(chan OrderCompleteEvent)
 	floorUptSub := make(chan FloorUptEvent)
@@ -70,34 +90,75 @@ func ControllerModule() {
 	obstructedSub := make(chan ObstructedEvent)
 	newCabOrderSub := make(chan NewCabOrderEvent)
 	assignedSub := make(chan AssignedEvent)
+	stopButtonSub := make(chan StopButtonEvent)
+	rejoinStateSub := make(chan RejoinStateEvent)
+	cancelOrderSub := make(chan CancelOrderEvent)
+	serviceModeSub := make(chan ServiceModeEvent)
+	orderRevokedSub := make(chan OrderRevokedEvent)
 
-	eventManager.AddPublishers(orderCompletePub, elevatorCtrlPub, costResultPub, availabilityPub, OrderLampCtrPub, OrderLampsOffCtrPub)
-	eventManager.AddSubscribers(orderCompleteSub, floorUptSub, newOrderSub, obstructedSub, newCabOrderSub, assignedSub)
+	eventManager.AddPublishers(orderCompletePub, elevatorCtrlPub, costResultPub, availabilityPub, OrderLampCtrPub, OrderLampsOffCtrPub, cabOrdersBackupPub, elevatorStatePub)
+	eventManager.AddSubscribers(orderCompleteSub, floorUptSub, newOrderSub, obstructedSub, newCabOrderSub, assignedSub, stopButtonSub, rejoinStateSub,
+		cancelOrderSub, serviceModeSub, orderRevokedSub)
 
 	doorTimer = timerInit()
 	obstructTimer = timerInit()
 	inBetweenFloorTimer = timerInit()
 
 	backupFileName := "cab_orders_backup" + strconv.Itoa(elevatorState.ElevatorID)
-	orders := getBackupedCabOrders(backupFileName)
+	orders := loadCabBackup(backupFileName)
 	addBackupedCaborders(&elevatorState, orders)
-	backupFile, err := os.Create(backupFileName)
-	utils.CheckError(err)
+
+	//Cab orders are only shared with the other elevators after their copies have been merged in, so a
+	//restarted elevator with lost cab orders does not overwrite the copies before getting them back. Copies are
+	//merged from every elevator until Network.RejoinTimeout after the first, as an elevator that has just
+	//started has none.
+	cabOrdersShared := false
+	cabOrdersShareTimer := timerInit()
+	cabOrdersMerging := false
+	backup := func() {
+		if err := saveCabBackup(backupFileName, getCabOrders(elevatorState)); err != nil {
+			log.PrintErr("Could not back up cab orders:", err)
+		}
+		if cabOrdersShared {
+			cabOrdersBackupPub <- CabOrdersBackupEvent{utils.ELEVATOR_ID, getCabOrders(elevatorState)}
+		}
+	}
+	//Rewrite the backup at once, to finish migrating an old backup
+	backup()
+
+	//The state is published when it has changed after an event, and periodically for elevators that connect later
+	var publishedState ElevatorStateEvent
+	publishState := func(always bool) {
+		state := elevatorState.copyState()
+		evt := ElevatorStateEvent{state.ElevatorID, state.Floor, state.Behaviour, state.Movement, state.Available, state.ActiveOrders}
+		if always || !reflect.DeepEqual(evt, publishedState) {
+			elevatorStatePub <- evt
+			publishedState = evt
+		}
+	}
+	stateTicker := time.NewTicker(utils.Config.Network.StateInterval.Duration)
 
 	initLamps()
 	for {
 		select {
+		case <-stateTicker.C:
+			publishState(true)
 		case evt := <-floorUptSub:
+			if elevatorState.Behaviour == behaviourStopped {
+				elevatorState.Floor = evt.Floor
+				stoppedAtFloor = true
+				break
+			}
 			newFloor := evt.Floor
 			d := elevatorControlOnFloorUpt(newFloor)
 			elevatorCtrlPub <- d
 			if d.Movement == moveStop {
 				inBetweenFloorTimer.Stop()
-				OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, evt.Floor}
+				OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, evt.Floor, takeOrderTraces(evt.Floor)}
 				orderCompletePub <- OrderComplete
-				backupCabOrders(backupFile, elevatorState.ActiveOrders)
+				backup()
 			} else if elevatorState.Available {
-				resetTimer(inBetweenFloorTimer, utils.MAX_TRAVEL_TIME)
+				resetTimer(inBetweenFloorTimer, utils.Config.Elevator.MaxTravelTime.Duration)
 
 			}
 		case evt := <-orderCompleteSub:
@@ -112,14 +173,21 @@ func ControllerModule() {
 			for i := 0; i < utils.ORDER_TYPE_NUM-1; i++ {
 				elevatorState.ActiveOrders[evt.Floor][i] = 0
 			}
+			if evt.ElevatorID != utils.ELEVATOR_ID {
+				clearHallOrderTraces(evt.Floor)
+			}
 
 		case evt := <-newOrderSub:
 			cost := TimeToServeOrder(elevatorState, evt.OrderType, evt.Floor)
-			d := CostResultEvent{utils.ELEVATOR_ID, evt.OrderID, cost, evt.Floor, evt.OrderType}
+			d := CostResultEvent{utils.ELEVATOR_ID, evt.OrderID, cost, evt.Floor, evt.OrderType, evt.TraceID,
+				elevatorState.Floor, queueLength(elevatorState)}
 			if elevatorState.Available {
 				costResultPub <- d
 			}
 		case <-doorTimer.C:
+			if elevatorState.Behaviour == behaviourStopped {
+				break
+			}
 			d := ElevatorCtrlEvent{}
 			d.Floor = elevatorState.Floor
 			if elevatorState.Behaviour == behaviourObstructed {
@@ -130,33 +198,33 @@ func ControllerModule() {
 				d.Movement = elevatorState.Movement
 				d.Behaviour = elevatorState.Behaviour
 				if d.Movement != moveStop && elevatorState.Available {
-					resetTimer(inBetweenFloorTimer, utils.MAX_TRAVEL_TIME)
+					resetTimer(inBetweenFloorTimer, utils.Config.Elevator.MaxTravelTime.Duration)
 				}
 			}
 			elevatorCtrlPub <- d
 		case evt := <-obstructedSub:
+			obstructed = evt.Obstructed
 
 			d := ElevatorCtrlEvent{}
 			d.Floor = elevatorState.Floor
 			if evt.Obstructed && elevatorState.Behaviour == behaviourDoorOpen {
-				resetTimer(obstructTimer, utils.MAX_OBSTRUCT_TIME)
+				resetTimer(obstructTimer, utils.Config.Elevator.MaxObstructTime.Duration)
 				elevatorState.Behaviour = behaviourObstructed
 				d.Movement = moveStop
 				d.Behaviour = behaviourDoorOpen
 				elevatorCtrlPub <- d
 			} else if !evt.Obstructed && elevatorState.Behaviour == behaviourObstructed {
 				obstructTimer.Stop()
-				if !elevatorState.Available {
+				if !elevatorState.Available && !outOfService {
 					elevatorState.Available = true
 					d := AvailabilityEvent{elevatorState.ElevatorID, true}
 					availabilityPub <- d
 				}
-				elevatorState.Available = true
 				chooseDirUptState()
 				d.Behaviour = elevatorState.Behaviour
 				d.Movement = elevatorState.Movement
 				if d.Movement != moveStop {
-					resetTimer(inBetweenFloorTimer, utils.MAX_TRAVEL_TIME)
+					resetTimer(inBetweenFloorTimer, utils.Config.Elevator.MaxTravelTime.Duration)
 				} else {
 					l := OrderLampsOffCtrEvent{elevatorState.Floor, true}
 					OrderLampsOffCtrPub <- l
@@ -164,26 +232,104 @@ func ControllerModule() {
 				elevatorCtrlPub <- d
 			}
 		case <-obstructTimer.C:
+			if elevatorState.Behaviour == behaviourStopped {
+				break
+			}
 			elevatorState.Available = false
 			d := AvailabilityEvent{elevatorState.ElevatorID, elevatorState.Available}
 			availabilityPub <- d
 			deleteHallOrders()
 		case evt := <-newCabOrderSub:
+			setOrderTrace(evt.Floor, evt.OrderType, evt.TraceID)
 			newOrderController(evt.Floor, evt.OrderType, OrderLampsOffCtrPub, OrderLampCtrPub, elevatorCtrlPub, orderCompletePub)
 			l := OrderLampCtrEvent{evt.Floor, evt.OrderType, true}
 			OrderLampCtrPub <- l
-			backupCabOrders(backupFile, elevatorState.ActiveOrders)
+			backup()
 		case evt := <-assignedSub:
-			if !evt.SingleMode {
-				l := OrderLampCtrEvent{evt.Floor, evt.OrderType, true}
-				OrderLampCtrPub <- l
-			}
+			// Hall lamps are turned on by the active orders module when all elevators agree on the order
 			if evt.ElevatorID == elevatorState.ElevatorID {
+				setOrderTrace(evt.Floor, evt.OrderType, evt.TraceID)
 				newOrderController(evt.Floor, evt.OrderType, OrderLampsOffCtrPub, OrderLampCtrPub, elevatorCtrlPub, orderCompletePub)
 			}
 		case <-inBetweenFloorTimer.C:
+			if elevatorState.Behaviour == behaviourStopped {
+				break
+			}
 			go onMotorStop(availabilityPub, elevatorCtrlPub)
+		case evt := <-rejoinStateSub:
+			if evt.TargetID != utils.ELEVATOR_ID {
+				break
+			}
+			if cabOrdersShared {
+				backup()
+				break
+			}
+			for floor, order := range evt.CabOrders {
+				if order == 1 && floor < utils.FLOOR_NUM && elevatorState.ActiveOrders[floor][orderCab] == 0 {
+					log.PrintInf("Restored cab order on floor", floor, "from elevator", evt.ElevatorID)
+					newOrderController(floor, orderCab, OrderLampsOffCtrPub, OrderLampCtrPub, elevatorCtrlPub, orderCompletePub)
+					OrderLampCtrPub <- OrderLampCtrEvent{floor, orderCab, true}
+				}
+			}
+			if !cabOrdersMerging {
+				cabOrdersMerging = true
+				resetTimer(cabOrdersShareTimer, utils.Config.Network.RejoinTimeout.Duration)
+			}
+			backup()
+		case <-cabOrdersShareTimer.C:
+			cabOrdersShared = true
+			backup()
+		case evt := <-cancelOrderSub:
+			if evt.OrderType == orderCab && evt.ElevatorID != utils.ELEVATOR_ID {
+				break
+			}
+			if elevatorState.ActiveOrders[evt.Floor][evt.OrderType] == 0 {
+				break
+			}
+			log.PrintInf("Cancelled order on floor", evt.Floor, "type", evt.OrderType)
+			elevatorState.ActiveOrders[evt.Floor][evt.OrderType] = 0
+			forgetOrderTrace(evt.Floor, evt.OrderType)
+			// Hall lamps are turned off by the active orders module when the call is no longer outstanding
+			if evt.OrderType == orderCab {
+				OrderLampCtrPub <- OrderLampCtrEvent{evt.Floor, orderCab, false}
+				backup()
+			}
+		case evt := <-orderRevokedSub:
+			if evt.ElevatorID != utils.ELEVATOR_ID || elevatorState.ActiveOrders[evt.Floor][evt.OrderType] == 0 {
+				break
+			}
+			log.PrintInf("Hall order on floor", evt.Floor, "type", evt.OrderType, "given to another elevator")
+			elevatorState.ActiveOrders[evt.Floor][evt.OrderType] = 0
+			forgetOrderTrace(evt.Floor, evt.OrderType)
+		case evt := <-serviceModeSub:
+			if evt.ElevatorID != utils.ELEVATOR_ID || evt.InService != outOfService {
+				break
+			}
+			outOfService = !evt.InService
+			log.PrintInf("In service:", evt.InService)
+			if outOfService && elevatorState.Available {
+				elevatorState.Available = false
+				availabilityPub <- AvailabilityEvent{elevatorState.ElevatorID, false}
+				deleteHallOrders()
+			} else if !outOfService && !elevatorState.Available && elevatorState.Behaviour != behaviourStopped &&
+				elevatorState.Behaviour != behaviourObstructed {
+				elevatorState.Available = true
+				availabilityPub <- AvailabilityEvent{elevatorState.ElevatorID, true}
+			}
+		case evt := <-stopButtonSub:
+			if evt.Stopped && elevatorState.Behaviour != behaviourStopped {
+				elevatorCtrlPub <- onStopPressed(evt.AtFloor, availabilityPub)
+			} else if !evt.Stopped && elevatorState.Behaviour == behaviourStopped {
+				elevatorCtrlPub <- onStopReleased(availabilityPub)
+				if stoppedAtFloor && ordersOnFloor(elevatorState, elevatorState.Floor) {
+					clearOrderOnCurrentFloor(&elevatorState)
+					OrderLampsOffCtrPub <- OrderLampsOffCtrEvent{elevatorState.Floor, true}
+					orderCompletePub <- OrderCompleteEvent{utils.ELEVATOR_ID, elevatorState.Floor, takeOrderTraces(elevatorState.Floor)}
+					backup()
+				}
+			}
 		}
+		publishState(false)
 	}
 }
 
@@ -193,66 +339,39 @@ func initLamps() {
 	}
 }
 
-func resetTimer(timer *time.Timer, sec int) {
+func resetTimer(timer *time.Timer, d time.Duration) {
 	timer.Stop()
-	timer.Reset(time.Duration(sec) * time.Second)
+	timer.Reset(d)
 }
 
-func addBackupedCaborders(elevator *ElevatorState, orders [utils.FLOOR_NUM]int) {
+func addBackupedCaborders(elevator *ElevatorState, orders []int) {
 	for floor, order := range orders {
 		elevator.ActiveOrders[floor][orderCab] = order
 	}
 }
 
-func getBackupedCabOrders(filename string) [utils.FLOOR_NUM]int {
-	file := openFile(filename)
-	file.Seek(0, 0)
-	scanner := bufio.NewScanner(file)
-	var orders [utils.FLOOR_NUM]int
-	i := 0
-	for scanner.Scan() {
-		num, err := strconv.Atoi(scanner.Text())
-		utils.CheckError(err)
-		orders[i] = num
-		i++
-	}
-	defer file.Close()
-	return orders
-}
-
-func backupCabOrders(file *os.File, orders [utils.FLOOR_NUM][utils.ORDER_TYPE_NUM]int) {
-	file.Seek(0, 0)
-	file.Truncate(0)
-	for floor := range orders {
-		_, err := file.WriteString(fmt.Sprintf("%d\n", orders[floor][orderCab]))
-		utils.CheckError(err)
-	}
-}
-
-func openFile(filename string) *os.File {
-	var file *os.File
-	var err error
-	if fileExists(filename) {
-		file, err = os.OpenFile(filename, os.O_RDWR, 0644)
-		utils.CheckError(err)
-
-	} else {
-		file, err = os.Create(filename)
-		utils.CheckError(err)
+//queueLength returns the number of active orders of the elevator
+func queueLength(state ElevatorState) int {
+	n := 0
+	for floor := range state.ActiveOrders {
+		for _, order := range state.ActiveOrders[floor] {
+			n += order
+		}
 	}
-	return file
+	return n
 }
 
-func fileExists(filename string) bool {
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return false
-	} else {
-		return true
+func getCabOrders(state ElevatorState) []int {
+	orders := make([]int, len(state.ActiveOrders))
+	for floor := range state.ActiveOrders {
+		orders[floor] = state.ActiveOrders[floor][orderCab]
 	}
+	return orders
 }
 
 //Constantly tries to turn on motor until the floor has been updated, meaning the motor has started working again
 func onMotorStop(availabilityPub chan AvailabilityEvent, elevatorCtrlPub chan ElevatorCtrlEvent) {
+	motorStops.Inc()
 	elevatorState.Available = false
 	d := AvailabilityEvent{utils.ELEVATOR_ID, elevatorState.Available}
 
@@ -274,12 +393,79 @@ func onMotorStop(availabilityPub chan AvailabilityEvent, elevatorCtrlPub chan El
 		if floor != elevatorState.Floor {
 			break
 		}
-		elevatorCtrlPub <- c
+		// The motor must not be started while the stop button is active
+		if elevatorState.Behaviour != behaviourStopped {
+			elevatorCtrlPub <- c
+		}
 		time.Sleep(1 * time.Second)
 	}
-	elevatorState.Available = true
-	a := AvailabilityEvent{elevatorState.ElevatorID, elevatorState.Available}
-	availabilityPub <- a
+	if !outOfService {
+		elevatorState.Available = true
+		a := AvailabilityEvent{elevatorState.ElevatorID, elevatorState.Available}
+		availabilityPub <- a
+	}
+}
+
+//onStopPressed halts the elevator and hands its hall orders to the other elevators. The door is opened
+//if the elevator is at a floor, and kept closed if it is stopped between floors.
+func onStopPressed(atFloor bool, availabilityPub chan AvailabilityEvent) ElevatorCtrlEvent {
+	doorTimer.Stop()
+	obstructTimer.Stop()
+	inBetweenFloorTimer.Stop()
+
+	if elevatorState.Movement != moveStop {
+		movementBeforeStop = elevatorState.Movement
+	}
+	stoppedAtFloor = atFloor
+	elevatorState.Behaviour = behaviourStopped
+	elevatorState.Movement = moveStop
+	log.PrintInf("Stop button pressed, at floor:", atFloor)
+
+	if elevatorState.Available {
+		elevatorState.Available = false
+		availabilityPub <- AvailabilityEvent{elevatorState.ElevatorID, false}
+		deleteHallOrders()
+	}
+
+	d := ElevatorCtrlEvent{elevatorState.Floor, behaviourStopped, moveStop}
+	if atFloor {
+		d.Behaviour = behaviourDoorOpen
+	}
+	return d
+}
+
+//onStopReleased makes the elevator available again. At a floor the door is held open for the normal
+//door open time before the elevator continues. Between floors it continues to the next floor.
+func onStopReleased(availabilityPub chan AvailabilityEvent) ElevatorCtrlEvent {
+	log.PrintInf("Stop button released")
+	if !outOfService {
+		elevatorState.Available = true
+		availabilityPub <- AvailabilityEvent{elevatorState.ElevatorID, true}
+	}
+
+	if stoppedAtFloor {
+		elevatorState.Movement = moveStop
+		if obstructed {
+			elevatorState.Behaviour = behaviourObstructed
+			resetTimer(obstructTimer, utils.Config.Elevator.MaxObstructTime.Duration)
+		} else {
+			elevatorState.Behaviour = behaviourDoorOpen
+			resetTimer(doorTimer, utils.Config.Elevator.DoorOpenTime.Duration)
+		}
+		return ElevatorCtrlEvent{elevatorState.Floor, behaviourDoorOpen, moveStop}
+	}
+
+	chooseDirUptState()
+	if elevatorState.Movement == moveStop {
+		// No orders, but the elevator has to get to a floor
+		elevatorState.Behaviour = behaviourMoving
+		elevatorState.Movement = movementBeforeStop
+		if elevatorState.Movement == moveStop {
+			elevatorState.Movement = moveDown
+		}
+	}
+	resetTimer(inBetweenFloorTimer, utils.Config.Elevator.MaxTravelTime.Duration)
+	return ElevatorCtrlEvent{elevatorState.Floor, elevatorState.Behaviour, elevatorState.Movement}
 }
 
 func timerInit() *time.Timer {
@@ -316,14 +502,14 @@ func newOrderController(floor int, orderType OrderType,
 		d, ButtonLamp := elevatorCtrFromOrder(floor)
 		elevatorCtrlPub <- d
 		if elevatorState.Available && d.Movement != moveStop {
-			resetTimer(inBetweenFloorTimer, utils.MAX_TRAVEL_TIME)
+			resetTimer(inBetweenFloorTimer, utils.Config.Elevator.MaxTravelTime.Duration)
 
 		}
 		if ButtonLamp {
 			AllButtons := true
 			l := OrderLampsOffCtrEvent{floor, AllButtons}
 			OrderLampsOffCtrPub <- l
-			OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, floor}
+			OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, floor, takeOrderTraces(floor)}
 			orderCompletePub <- OrderComplete
 		}
 	case behaviourObstructed:
@@ -332,22 +518,31 @@ func newOrderController(floor int, orderType OrderType,
 			AllButtons := true
 			l := OrderLampsOffCtrEvent{floor, AllButtons}
 			OrderLampsOffCtrPub <- l
-			OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, floor}
+			OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, floor, takeOrderTraces(floor)}
 			orderCompletePub <- OrderComplete
 		}
 	case behaviourDoorOpen:
 		if floor == elevatorState.Floor {
-			doorTimer.Reset(utils.DOOR_OPEN_TIME * time.Second)
+			doorTimer.Reset(utils.Config.Elevator.DoorOpenTime.Duration)
 			clearOrderOnCurrentFloor(&elevatorState)
 			AllButtons := true
 			l := OrderLampsOffCtrEvent{floor, AllButtons}
 			OrderLampsOffCtrPub <- l
-			OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, floor}
+			OrderComplete := OrderCompleteEvent{utils.ELEVATOR_ID, floor, takeOrderTraces(floor)}
 			orderCompletePub <- OrderComplete
 		}
 	}
 }
 
+func ordersOnFloor(state ElevatorState, floor int) bool {
+	for i := 0; i < utils.ORDER_TYPE_NUM; i++ {
+		if state.ActiveOrders[floor][i] == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 func clearOrderOnCurrentFloor(state *ElevatorState) {
 	for i := 0; i < utils.ORDER_TYPE_NUM; i++ {
 		state.ActiveOrders[state.Floor][i] = 0
@@ -361,7 +556,7 @@ func elevatorControlOnFloorUpt(newFloor int) ElevatorCtrlEvent {
 		elevatorState.Behaviour = behaviourDoorOpen
 		clearOrderOnCurrentFloor(&elevatorState)
 		elevatorCtr := ElevatorCtrlEvent{newFloor, behaviourDoorOpen, moveStop}
-		resetTimer(doorTimer, utils.DOOR_OPEN_TIME)
+		resetTimer(doorTimer, utils.Config.Elevator.DoorOpenTime.Duration)
 		return elevatorCtr
 	} else if newFloor == utils.FLOOR_NUM-1 || newFloor == 0 {
 		elevatorCtr := ElevatorCtrlEvent{newFloor, behaviourIdle, moveStop}
@@ -381,7 +576,7 @@ func elevatorCtrFromOrder(FloorRequest int) (ElevatorCtrlEvent, bool) {
 		elevatorState.Behaviour = behaviourDoorOpen
 		clearOrderOnCurrentFloor(&elevatorState)
 		elevatorCtr = ElevatorCtrlEvent{FloorRequest, behaviourDoorOpen, moveStop}
-		doorTimer.Reset(utils.DOOR_OPEN_TIME * time.Second)
+		doorTimer.Reset(utils.Config.Elevator.DoorOpenTime.Duration)
 		ButtonLampsOff = true
 	} else if elevatorState.Behaviour == behaviourIdle {
 		chooseDirUptState()
@@ -4,8 +4,11 @@ import (
 	"./utils"
 )
 
+//TimeToServeOrder simulates the elevator and returns the time in milliseconds until the order is served
 func TimeToServeOrder(state ElevatorState, b OrderType, f int) int {
-	e := state
+	travelTime := int(utils.Config.Elevator.TravelTime.Milliseconds())
+	doorOpenTime := int(utils.Config.Elevator.DoorOpenTime.Milliseconds())
+	e := state.copyState()
 	e.ActiveOrders[f][b] = 1
 
 	var arrivedAtOrder = 0
@@ -23,10 +26,10 @@ func TimeToServeOrder(state ElevatorState, b OrderType, f int) int {
 			return duration
 		}
 	case behaviourMoving:
-		duration += utils.TRAVEL_TIME / 2
+		duration += travelTime / 2
 		e.Floor += int(e.Movement)
 	case behaviourDoorOpen:
-		duration -= utils.DOOR_OPEN_TIME / 2
+		duration -= doorOpenTime / 2
 	}
 
 	for {
@@ -35,11 +38,11 @@ func TimeToServeOrder(state ElevatorState, b OrderType, f int) int {
 			if arrivedAtOrder == 1 {
 				return duration
 			}
-			duration += utils.DOOR_OPEN_TIME
+			duration += doorOpenTime
 			e.Movement = Requests_chooseDirection(e)
 		}
 		e.Floor += int(e.Movement)
-		duration += utils.TRAVEL_TIME
+		duration += travelTime
 	}
 }
 
@@ -0,0 +1,347 @@
+package elevator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"./eventManager"
+	"./log"
+	"./utils"
+)
+
+//elevatorView is the state of one elevator as shown on the dashboard
+type elevatorView struct {
+	ID        int
+	Connected bool
+	// Known is false until a state has been received from the elevator
+	Known      bool
+	Floor      int
+	Direction  string
+	Behaviour  string
+	DoorOpen   bool
+	Available  bool
+	CabOrders  []int
+	HallOrders []hallOrderView
+	// Age is the time since the last state was received
+	Age string
+}
+
+type hallOrderView struct {
+	Floor     int
+	Direction string
+}
+
+//dashboardView is sent to the dashboard every time something changes
+type dashboardView struct {
+	Self      int
+	Floors    int
+	Peers     []int
+	Elevators []elevatorView
+}
+
+//The dashboard state, updated by the dashboard module and read by the HTTP handlers
+var dashboard = struct {
+	sync.Mutex
+	states    map[int]ElevatorStateEvent
+	updated   map[int]time.Time
+	connected map[int]bool
+	// clients are the open event streams, each gets the latest view
+	clients map[chan []byte]struct{}
+}{
+	states:    make(map[int]ElevatorStateEvent),
+	updated:   make(map[int]time.Time),
+	connected: make(map[int]bool),
+	clients:   make(map[chan []byte]struct{}),
+}
+
+//How often the view is sent to the dashboard when nothing changes, to update the age of the states and keep the
+//connection open
+const dashboardRefresh = 5 * time.Second
+
+//DashboardModule serves a live view of all elevators at /dashboard of the control interface. It is built from the
+//ElevatorStateEvents of all elevators and the ConnectionEvents of the network module.
+func DashboardModule() {
+	controlMux.HandleFunc("/dashboard", handleDashboardPage)
+	controlMux.HandleFunc("/dashboard/state", handleDashboardState)
+	controlMux.HandleFunc("/dashboard/events", handleDashboardEvents)
+
+	elevatorStateSub := eventManager.Subscribe[ElevatorStateEvent]()
+	connectionSub := eventManager.Subscribe[ConnectionEvent]()
+	refresh := time.NewTicker(dashboardRefresh)
+
+	log.PrintInf("Started")
+	for {
+		select {
+		case evt := <-elevatorStateSub:
+			dashboard.Lock()
+			dashboard.states[evt.ElevatorID] = evt
+			dashboard.updated[evt.ElevatorID] = time.Now()
+			dashboard.Unlock()
+		case evt := <-connectionSub:
+			dashboard.Lock()
+			dashboard.connected[evt.ElevatorID] = evt.Connect
+			dashboard.Unlock()
+		case <-refresh.C:
+		}
+		broadcastDashboard()
+	}
+}
+
+//dashboardSnapshot returns the current view, the dashboard must be locked
+func dashboardSnapshot() dashboardView {
+	view := dashboardView{Self: utils.ELEVATOR_ID, Floors: utils.FLOOR_NUM, Peers: []int{}, Elevators: []elevatorView{}}
+	for id, connected := range dashboard.connected {
+		if connected {
+			view.Peers = append(view.Peers, id)
+		}
+	}
+	sort.Ints(view.Peers)
+
+	for id := 0; id < utils.ELEVATOR_MAX_NUM; id++ {
+		state, known := dashboard.states[id]
+		connected := id == utils.ELEVATOR_ID || dashboard.connected[id]
+		if !known && !connected {
+			continue
+		}
+		e := elevatorView{ID: id, Connected: connected, Known: known, CabOrders: []int{}, HallOrders: []hallOrderView{}}
+		if known {
+			e.Floor = state.Floor
+			e.Direction = movementName(state.Movement)
+			e.Behaviour = behaviourName(state.Behaviour)
+			e.DoorOpen = state.Behaviour == behaviourDoorOpen || state.Behaviour == behaviourObstructed
+			e.Available = state.Available
+			for floor, orders := range state.ActiveOrders {
+				if orders[orderCab] != 0 {
+					e.CabOrders = append(e.CabOrders, floor)
+				}
+				for _, orderType := range []OrderType{orderHallUp, orderHallDown} {
+					if orders[orderType] != 0 {
+						e.HallOrders = append(e.HallOrders, hallOrderView{floor, orderTypeName(orderType)})
+					}
+				}
+			}
+			e.Age = time.Since(dashboard.updated[id]).Round(time.Second).String()
+		}
+		view.Elevators = append(view.Elevators, e)
+	}
+	return view
+}
+
+//broadcastDashboard sends the current view to all open event streams. A client that has not read the previous
+//view gets the new one instead.
+func broadcastDashboard() {
+	dashboard.Lock()
+	defer dashboard.Unlock()
+	if len(dashboard.clients) == 0 {
+		return
+	}
+	data, err := json.Marshal(dashboardSnapshot())
+	if err != nil {
+		log.PrintErr("Could not encode dashboard:", err)
+		return
+	}
+	for client := range dashboard.clients {
+		select {
+		case <-client:
+		default:
+		}
+		client <- data
+	}
+}
+
+func handleDashboardState(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	dashboard.Lock()
+	view := dashboardSnapshot()
+	dashboard.Unlock()
+	writeJSON(w, view)
+}
+
+//handleDashboardEvents streams the view as server-sent events
+func handleDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	client := make(chan []byte, 1)
+	dashboard.Lock()
+	data, err := json.Marshal(dashboardSnapshot())
+	dashboard.clients[client] = struct{}{}
+	dashboard.Unlock()
+	defer func() {
+		dashboard.Lock()
+		delete(dashboard.clients, client)
+		dashboard.Unlock()
+	}()
+	if err != nil {
+		log.PrintErr("Could not encode dashboard:", err)
+		return
+	}
+
+	for {
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+		select {
+		case data = <-client:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func handleDashboardPage(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, dashboardPage)
+}
+
+func movementName(m Movement) string {
+	switch m {
+	case moveUp:
+		return "up"
+	case moveDown:
+		return "down"
+	}
+	return "stopped"
+}
+
+func behaviourName(b ElevatorBehaviour) string {
+	switch b {
+	case behaviourIdle:
+		return "idle"
+	case behaviourDoorOpen:
+		return "door open"
+	case behaviourMoving:
+		return "moving"
+	case behaviourObstructed:
+		return "obstructed"
+	case behaviourStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+func orderTypeName(t OrderType) string {
+	switch t {
+	case orderHallUp:
+		return "up"
+	case orderHallDown:
+		return "down"
+	}
+	return "cab"
+}
+
+//dashboardPage draws the building with one column per elevator, and a table with the details of each elevator
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Elevators</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: center; }
+.car { background: #4a90d9; color: white; font-weight: bold; }
+.door { background: #e8a33d; }
+.unavailable { background: #d9534f; }
+.disconnected { color: #aaa; }
+.call { color: #d9534f; font-weight: bold; }
+#status { color: #888; }
+</style>
+</head>
+<body>
+<h1>Elevators</h1>
+<p id="status">Connecting...</p>
+<table id="building"></table>
+<table id="details"></table>
+<script>
+function cell(row, text, cls) {
+  var c = row.insertCell();
+  c.textContent = text;
+  if (cls) c.className = cls;
+  return c;
+}
+
+function header(table, names) {
+  var row = table.createTHead().insertRow();
+  names.forEach(function (n) {
+    var th = document.createElement("th");
+    th.textContent = n;
+    row.appendChild(th);
+  });
+}
+
+function draw(view) {
+  document.getElementById("status").textContent = "Elevator " + view.Self + ", connected to " +
+    (view.Peers.length ? "elevators " + view.Peers.join(", ") : "no other elevators");
+
+  var building = document.getElementById("building");
+  building.innerHTML = "";
+  header(building, ["Floor"].concat(view.Elevators.map(function (e) { return "Elevator " + e.ID; })));
+  var body = building.createTBody();
+  for (var floor = view.Floors - 1; floor >= 0; floor--) {
+    var row = body.insertRow();
+    cell(row, floor);
+    view.Elevators.forEach(function (e) {
+      var text = "", cls = e.Connected ? "" : "disconnected";
+      if (e.Known && e.Floor === floor) {
+        text = {up: "▲", down: "▼", stopped: "■"}[e.Direction];
+        cls += e.Available ? (e.DoorOpen ? " door" : " car") : " unavailable";
+      }
+      e.HallOrders.forEach(function (o) {
+        if (o.Floor === floor) text += o.Direction === "up" ? " ↑" : " ↓";
+      });
+      if (e.CabOrders.indexOf(floor) >= 0) text += " ●";
+      cell(row, text, cls);
+    });
+  }
+
+  var details = document.getElementById("details");
+  details.innerHTML = "";
+  header(details, ["Elevator", "Connected", "Floor", "Direction", "Behaviour", "Door", "Available",
+    "Cab orders", "Hall orders", "Updated"]);
+  body = details.createTBody();
+  view.Elevators.forEach(function (e) {
+    var row = body.insertRow();
+    row.className = e.Connected ? "" : "disconnected";
+    cell(row, e.ID);
+    cell(row, e.Connected ? "yes" : "no");
+    if (!e.Known) {
+      cell(row, "no state received").colSpan = 8;
+      return;
+    }
+    cell(row, e.Floor);
+    cell(row, e.Direction);
+    cell(row, e.Behaviour);
+    cell(row, e.DoorOpen ? "open" : "closed");
+    cell(row, e.Available ? "yes" : "no", e.Available ? "" : "call");
+    cell(row, e.CabOrders.join(", "));
+    cell(row, e.HallOrders.map(function (o) { return o.Floor + " " + o.Direction; }).join(", "));
+    cell(row, e.Age + " ago");
+  });
+}
+
+var events = new EventSource("/dashboard/events");
+events.onmessage = function (msg) { draw(JSON.parse(msg.data)); };
+events.onerror = function () { document.getElementById("status").textContent = "Disconnected, reconnecting..."; };
+</script>
+</body>
+</html>
+`
@@ -1,10 +1,7 @@
 package elevator
 
 import (
-	"fmt"
-	"net"
-	"strconv"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"./eventManager"
@@ -14,13 +11,6 @@ import (
 
 const _pollRate = 20 * time.Millisecond
 
-var _mtx sync.Mutex
-var _conn net.Conn
-
-func init() {
-	initTCP()
-}
-
 //Driver Module Function initializes the Driver module and start the go routines for
 //polling buttons and sensor. The for-select cases are events from controller to set the behaviour
 //of the elevator
@@ -32,19 +22,19 @@ func DriverModule() {
 	newCabOrderPub := make(chan NewCabOrderEvent)
 	FloorUptPub := make(chan FloorUptEvent)
 	obstructedPub := make(chan ObstructedEvent)
+	stopButtonPub := make(chan StopButtonEvent)
 
 	ElevatorCtrSub := make(chan ElevatorCtrlEvent)
 	OrderLampCtrSub := make(chan OrderLampCtrEvent)
 	OrderLampsOffCtrSub := make(chan OrderLampsOffCtrEvent)
 
-	eventManager.AddPublishers(newOrderPub, newCabOrderPub, newCabOrderPub, FloorUptPub, obstructedPub) //, stoppedPub)
+	eventManager.AddPublishersFrom(eventManager.SourceDriver, newOrderPub, newCabOrderPub, FloorUptPub, obstructedPub, stopButtonPub)
 	eventManager.AddSubscribers(ElevatorCtrSub, OrderLampCtrSub, OrderLampsOffCtrSub)
 
-	_mtx = sync.Mutex{}
-
 	go pollButtons(newOrderPub, newCabOrderPub)
 	go pollFloorSensor(FloorUptPub)
 	go pollObstructionSwitch(obstructedPub)
+	go pollStopButton(stopButtonPub)
 
 	for {
 		select {
@@ -73,15 +63,11 @@ func setElevatorControl(evt ElevatorCtrlEvent) {
 }
 
 func SetMotorDirection(dir Movement) {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{1, byte(dir), 0, 0})
+	_io.SetMotorDirection(dir)
 }
 
 func SetButtonLamp(button OrderType, floor int, value bool) {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{2, byte(button), byte(floor), toByte(value)})
+	_io.SetButtonLamp(button, floor, value)
 }
 
 func TurnOffButtonLamps(floor int, AllButtons bool) {
@@ -96,38 +82,46 @@ func TurnOffButtonLamps(floor int, AllButtons bool) {
 
 }
 func SetFloorIndicator(floor int) {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{3, byte(floor), 0, 0})
+	_io.SetFloorIndicator(floor)
 }
 
 func SetDoorOpenLamp(value bool) {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{4, toByte(value), 0, 0})
+	_io.SetDoorOpenLamp(value)
+}
+
+func SetStopLamp(value bool) {
+	_io.SetStopLamp(value)
+}
+
+//Order IDs are shared by the buttons and the operator interface
+var orderCounter uint64
+
+//newOrderID returns the ID of a new order. The 6 lowest bits are a counter, the rest is the elevator ID.
+func newOrderID() int {
+	return (utils.ELEVATOR_ID << 6) + int((atomic.AddUint64(&orderCounter, 1)-1)&63)
 }
 
 //The pollButtons function is modified to publish events when new hall and cab orders are pushed.
 func pollButtons(newOrderPub chan<- NewOrderEvent, newCabOrderPub chan<- NewCabOrderEvent) {
-	prev := make([][3]bool, utils.FLOOR_NUM)
+	prev := make([][utils.ORDER_TYPE_NUM]bool, utils.FLOOR_NUM)
 
-	i := 0
 	for {
 		time.Sleep(_pollRate)
 
 		for f := 0; f < utils.FLOOR_NUM; f++ {
 
-			for b := OrderType(0); b < 3; b++ {
+			for b := OrderType(0); b < utils.ORDER_TYPE_NUM; b++ {
 				v := getButton(b, f)
 
 				if v != prev[f][b] && v {
-					orderID := (utils.ELEVATOR_ID << 6) + (i & 63)
-					i++
+					orderID := newOrderID()
 					if OrderType(b) == orderCab {
-						evt := NewCabOrderEvent{utils.ELEVATOR_ID, f, orderID, orderCab}
+						evt := NewCabOrderEvent{utils.ELEVATOR_ID, f, orderID, orderCab, newTraceID()}
+						notePressed(evt.TraceID, orderCab)
 						newCabOrderPub <- evt
 					} else {
-						evt := NewOrderEvent{utils.ELEVATOR_ID, f, orderID, OrderType(b)}
+						evt := NewOrderEvent{utils.ELEVATOR_ID, f, orderID, OrderType(b), newTraceID()}
+						notePressed(evt.TraceID, evt.OrderType)
 						newOrderPub <- evt
 					}
 				}
@@ -165,42 +159,36 @@ func pollObstructionSwitch(obstructedPub chan<- ObstructedEvent) {
 	}
 }
 
-func initTCP() {
-	var err error
-	fmt.Println("Elevator port" + strconv.Itoa(utils.ELEVATOR_PORT))
-	_conn, err = net.Dial("tcp", "localhost:"+strconv.Itoa(utils.ELEVATOR_PORT))
-	utils.CheckError(err)
+//pollStopButton lights the stop lamp and publishes a StopButtonEvent when the stop button is pressed and released
+func pollStopButton(stopButtonPub chan<- StopButtonEvent) {
+	prev := false
+	SetStopLamp(false)
+	for {
+		time.Sleep(_pollRate)
+		v := getStopButton()
+		if v != prev {
+			SetStopLamp(v)
+			evt := StopButtonEvent{utils.ELEVATOR_ID, v, getFloor() != -1}
+			stopButtonPub <- evt
+		}
+		prev = v
+	}
 }
 
 func getButton(button OrderType, floor int) bool {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{6, byte(button), byte(floor), 0})
-	var buf [4]byte
-	_conn.Read(buf[:])
-	return toBool(buf[1])
+	return _io.GetButton(button, floor)
 }
 
 func getFloor() int {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{7, 0, 0, 0})
-	var buf [4]byte
-	_conn.Read(buf[:])
-	if buf[1] != 0 {
-		return int(buf[2])
-	} else {
-		return -1
-	}
+	return _io.GetFloor()
+}
+
+func getStopButton() bool {
+	return _io.GetStopButton()
 }
 
 func getObstruction() bool {
-	_mtx.Lock()
-	defer _mtx.Unlock()
-	_conn.Write([]byte{9, 0, 0, 0})
-	var buf [4]byte
-	_conn.Read(buf[:])
-	return toBool(buf[1])
+	return _io.GetObstruction()
 }
 
 func toByte(a bool) byte {
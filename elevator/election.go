@@ -0,0 +1,154 @@
+package elevator
+
+import (
+	"time"
+
+	"./eventManager"
+	"./log"
+	"./utils"
+)
+
+//ElectionModule elects the leader that makes the decisions only one elevator may make, like giving out the hall
+//orders of a lost elevator. It is the bully algorithm: the connected elevator with the lowest ID wins, and an
+//elevator that finds a lower ID than the leader starts a new election. Every election starts a new term, and
+//leader announcements from an older term are ignored. The leader is published in a LeaderChangedEvent.
+func ElectionModule() {
+	electionPub := make(chan ElectionEvent)
+	electionAnswerPub := make(chan ElectionAnswerEvent)
+	leaderPub := make(chan LeaderEvent)
+	leaderChangedPub := make(chan LeaderChangedEvent)
+	eventManager.AddPublishers(electionPub, electionAnswerPub, leaderPub, leaderChangedPub)
+
+	connectSub := eventManager.Subscribe[ConnectionEvent]()
+	electionSub := eventManager.Subscribe[ElectionEvent]()
+	electionAnswerSub := eventManager.Subscribe[ElectionAnswerEvent]()
+	leaderSub := eventManager.Subscribe[LeaderEvent]()
+
+	timeout := utils.Config.Network.ElectionTimeout.Duration
+	connected := make(map[int]bool)
+	term := 0
+	leader := -1
+	// electing is set while waiting for an answer from a lower ID, answered when waiting for it to win
+	electing := false
+	answered := false
+	electionTimer := timerInit()
+	leaderTicker := time.NewTicker(utils.Config.Network.LeaderInterval.Duration)
+
+	setLeader := func(id int, newTerm int) {
+		term = newTerm
+		if id != leader {
+			log.PrintInf("Elevator", id, "is the leader in term", newTerm)
+			leader = id
+			leaderChanges.Inc()
+			leaderChangedPub <- LeaderChangedEvent{utils.ELEVATOR_ID, id, newTerm}
+		}
+	}
+	becomeLeader := func() {
+		electing = false
+		electionTimer.Stop()
+		setLeader(utils.ELEVATOR_ID, term)
+		leaderPub <- LeaderEvent{utils.ELEVATOR_ID, term}
+	}
+	startElection := func() {
+		term++
+		lowerConnected := false
+		for id := range connected {
+			if id < utils.ELEVATOR_ID {
+				lowerConnected = true
+			}
+		}
+		if !lowerConnected {
+			becomeLeader()
+			return
+		}
+		log.PrintDbg("Starting election in term", term)
+		electing, answered = true, false
+		electionPub <- ElectionEvent{utils.ELEVATOR_ID, term}
+		resetTimer(electionTimer, timeout)
+	}
+	//assertLeadership answers an elevator that does not know who leads. A new election is only held if this
+	//elevator is not the leader, as the leader with the lowest ID stays the leader.
+	assertLeadership := func() {
+		if leader == utils.ELEVATOR_ID {
+			leaderPub <- LeaderEvent{utils.ELEVATOR_ID, term}
+		} else if !electing {
+			startElection()
+		}
+	}
+
+	// The first election is held when the network module has had time to find the other elevators
+	resetTimer(electionTimer, utils.Config.Network.RejoinTimeout.Duration)
+	log.PrintInf("Started")
+	for {
+		select {
+		case evt := <-connectSub:
+			if evt.ElevatorID == utils.ELEVATOR_ID {
+				break
+			}
+			if evt.Connect {
+				connected[evt.ElevatorID] = true
+				if leader == utils.ELEVATOR_ID {
+					// a lower ID takes over, a higher ID is told who the leader is
+					if evt.ElevatorID < utils.ELEVATOR_ID {
+						startElection()
+					} else {
+						leaderPub <- LeaderEvent{utils.ELEVATOR_ID, term}
+					}
+				}
+				break
+			}
+			delete(connected, evt.ElevatorID)
+			if evt.ElevatorID == leader {
+				startElection()
+			}
+		case evt := <-electionSub:
+			if evt.ElevatorID == utils.ELEVATOR_ID {
+				break
+			}
+			if evt.Term > term {
+				term = evt.Term
+			}
+			if evt.ElevatorID > utils.ELEVATOR_ID {
+				electionAnswerPub <- ElectionAnswerEvent{utils.ELEVATOR_ID, evt.ElevatorID, term}
+				assertLeadership()
+			}
+		case evt := <-electionAnswerSub:
+			if evt.TargetID != utils.ELEVATOR_ID || !electing || evt.ElevatorID > utils.ELEVATOR_ID {
+				break
+			}
+			if evt.Term > term {
+				term = evt.Term
+			}
+			// a lower ID is alive, wait for it to announce itself
+			answered = true
+			resetTimer(electionTimer, 2*timeout)
+		case evt := <-leaderSub:
+			if evt.ElevatorID == utils.ELEVATOR_ID {
+				break
+			}
+			stale := evt.Term < term || (evt.Term == term && leader != -1 && evt.ElevatorID > leader)
+			if stale || evt.ElevatorID > utils.ELEVATOR_ID {
+				// this elevator or the known leader should lead, a new election brings the other elevator up to date
+				if evt.Term > term {
+					term = evt.Term
+				}
+				assertLeadership()
+				break
+			}
+			electing = false
+			electionTimer.Stop()
+			setLeader(evt.ElevatorID, evt.Term)
+		case <-electionTimer.C:
+			if electing && !answered {
+				// no lower ID answered
+				becomeLeader()
+			} else {
+				startElection()
+			}
+		case <-leaderTicker.C:
+			if leader == utils.ELEVATOR_ID {
+				leaderPub <- LeaderEvent{utils.ELEVATOR_ID, term}
+			}
+		}
+	}
+}
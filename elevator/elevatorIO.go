@@ -0,0 +1,129 @@
+package elevator
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"./utils"
+)
+
+//ElevatorIO is the hardware of one elevator. The driver module only talks to the hardware through this interface
+type ElevatorIO interface {
+	SetMotorDirection(dir Movement)
+	SetButtonLamp(button OrderType, floor int, value bool)
+	SetFloorIndicator(floor int)
+	SetDoorOpenLamp(value bool)
+	SetStopLamp(value bool)
+	GetButton(button OrderType, floor int) bool
+	GetFloor() int
+	GetStopButton() bool
+	GetObstruction() bool
+}
+
+//_io is the hardware used by the driver, set by InitElevatorIO before the modules are started
+var _io ElevatorIO
+
+//InitElevatorIO selects the hardware implementation by name: "tcp" connects to the elevator server,
+//"fake" uses an in-memory elevator and "record" records every call made to the elevator server
+func InitElevatorIO(kind string) error {
+	switch kind {
+	case "tcp":
+		io, err := NewTCPElevatorIO("localhost:" + strconv.Itoa(utils.ELEVATOR_PORT))
+		if err != nil {
+			return err
+		}
+		SetElevatorIO(io)
+	case "fake":
+		SetElevatorIO(NewFakeElevatorIO(utils.FLOOR_NUM))
+	case "record":
+		io, err := NewTCPElevatorIO("localhost:" + strconv.Itoa(utils.ELEVATOR_PORT))
+		if err != nil {
+			return err
+		}
+		SetElevatorIO(NewRecordingElevatorIO(io))
+	default:
+		return fmt.Errorf("unknown elevator io %q, expected tcp, fake or record", kind)
+	}
+	return nil
+}
+
+//SetElevatorIO sets the hardware used by the driver module
+func SetElevatorIO(io ElevatorIO) {
+	_io = io
+}
+
+//tcpElevatorIO talks to an elevator server (hardware or simulator) over the 4 byte command protocol
+type tcpElevatorIO struct {
+	mtx  sync.Mutex
+	conn net.Conn
+}
+
+//NewTCPElevatorIO connects to the elevator server on the given address
+func NewTCPElevatorIO(addr string) (ElevatorIO, error) {
+	fmt.Println("Elevator address " + addr)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpElevatorIO{conn: conn}, nil
+}
+
+func (e *tcpElevatorIO) SetMotorDirection(dir Movement) {
+	e.write([4]byte{1, byte(dir), 0, 0})
+}
+
+func (e *tcpElevatorIO) SetButtonLamp(button OrderType, floor int, value bool) {
+	e.write([4]byte{2, byte(button), byte(floor), toByte(value)})
+}
+
+func (e *tcpElevatorIO) SetFloorIndicator(floor int) {
+	e.write([4]byte{3, byte(floor), 0, 0})
+}
+
+func (e *tcpElevatorIO) SetDoorOpenLamp(value bool) {
+	e.write([4]byte{4, toByte(value), 0, 0})
+}
+
+func (e *tcpElevatorIO) SetStopLamp(value bool) {
+	e.write([4]byte{5, toByte(value), 0, 0})
+}
+
+func (e *tcpElevatorIO) GetButton(button OrderType, floor int) bool {
+	buf := e.query([4]byte{6, byte(button), byte(floor), 0})
+	return toBool(buf[1])
+}
+
+func (e *tcpElevatorIO) GetFloor() int {
+	buf := e.query([4]byte{7, 0, 0, 0})
+	if buf[1] != 0 {
+		return int(buf[2])
+	}
+	return -1
+}
+
+func (e *tcpElevatorIO) GetStopButton() bool {
+	buf := e.query([4]byte{8, 0, 0, 0})
+	return toBool(buf[1])
+}
+
+func (e *tcpElevatorIO) GetObstruction() bool {
+	buf := e.query([4]byte{9, 0, 0, 0})
+	return toBool(buf[1])
+}
+
+func (e *tcpElevatorIO) write(cmd [4]byte) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.conn.Write(cmd[:])
+}
+
+func (e *tcpElevatorIO) query(cmd [4]byte) [4]byte {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.conn.Write(cmd[:])
+	var buf [4]byte
+	e.conn.Read(buf[:])
+	return buf
+}
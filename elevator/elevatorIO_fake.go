@@ -0,0 +1,151 @@
+package elevator
+
+import (
+	"sync"
+)
+
+//FakeElevatorIO is an in-memory elevator. Outputs are stored and inputs are set by the test or tool driving it.
+//The cab does not move by itself, use SetFloor to move it.
+type FakeElevatorIO struct {
+	mtx            sync.Mutex
+	motor          Movement
+	buttonLamps    [][3]bool
+	buttons        [][3]bool
+	floorIndicator int
+	doorOpen       bool
+	stopLamp       bool
+	floor          int
+	stopButton     bool
+	obstruction    bool
+}
+
+//NewFakeElevatorIO returns a fake elevator with the given number of floors, with the cab between floors
+func NewFakeElevatorIO(floors int) *FakeElevatorIO {
+	return &FakeElevatorIO{
+		buttonLamps: make([][3]bool, floors),
+		buttons:     make([][3]bool, floors),
+		floor:       -1,
+	}
+}
+
+func (f *FakeElevatorIO) SetMotorDirection(dir Movement) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.motor = dir
+}
+
+func (f *FakeElevatorIO) SetButtonLamp(button OrderType, floor int, value bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if floor >= 0 && floor < len(f.buttonLamps) {
+		f.buttonLamps[floor][button] = value
+	}
+}
+
+func (f *FakeElevatorIO) SetFloorIndicator(floor int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.floorIndicator = floor
+}
+
+func (f *FakeElevatorIO) SetDoorOpenLamp(value bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.doorOpen = value
+}
+
+func (f *FakeElevatorIO) SetStopLamp(value bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.stopLamp = value
+}
+
+func (f *FakeElevatorIO) GetButton(button OrderType, floor int) bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if floor < 0 || floor >= len(f.buttons) {
+		return false
+	}
+	return f.buttons[floor][button]
+}
+
+func (f *FakeElevatorIO) GetFloor() int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.floor
+}
+
+func (f *FakeElevatorIO) GetStopButton() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.stopButton
+}
+
+func (f *FakeElevatorIO) GetObstruction() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.obstruction
+}
+
+//SetButton sets whether a button is held down
+func (f *FakeElevatorIO) SetButton(button OrderType, floor int, pressed bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.buttons[floor][button] = pressed
+}
+
+//SetFloor sets the floor sensor, -1 means between floors
+func (f *FakeElevatorIO) SetFloor(floor int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.floor = floor
+}
+
+//SetStopButton sets whether the stop button is held down
+func (f *FakeElevatorIO) SetStopButton(value bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.stopButton = value
+}
+
+//SetObstruction sets the obstruction switch
+func (f *FakeElevatorIO) SetObstruction(value bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.obstruction = value
+}
+
+//MotorDirection returns the last motor direction set by the driver
+func (f *FakeElevatorIO) MotorDirection() Movement {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.motor
+}
+
+//ButtonLamp returns whether a button lamp is lit
+func (f *FakeElevatorIO) ButtonLamp(button OrderType, floor int) bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.buttonLamps[floor][button]
+}
+
+//DoorOpen returns whether the door open lamp is lit
+func (f *FakeElevatorIO) DoorOpen() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.doorOpen
+}
+
+//StopLamp returns whether the stop lamp is lit
+func (f *FakeElevatorIO) StopLamp() bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.stopLamp
+}
+
+//FloorIndicator returns the floor shown on the floor indicator
+func (f *FakeElevatorIO) FloorIndicator() int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.floorIndicator
+}
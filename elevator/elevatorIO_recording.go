@@ -0,0 +1,135 @@
+package elevator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"./log"
+)
+
+//IOCall is one call made to the elevator hardware. Result is set for the input functions.
+type IOCall struct {
+	Time   time.Time
+	Name   string
+	Args   []interface{}
+	Result interface{}
+}
+
+func (c IOCall) String() string {
+	if c.Result == nil {
+		return fmt.Sprint(c.Name, c.Args)
+	}
+	return fmt.Sprint(c.Name, c.Args, " = ", c.Result)
+}
+
+//recordedCalls is how many of the latest calls are kept, so a long running recording does not grow without limit
+const recordedCalls = 4096
+
+//RecordingElevatorIO passes every call on to another ElevatorIO and records it.
+//Output calls are logged, input calls are only logged when the result changes, as they are polled.
+//Only the latest recordedCalls calls are kept, all of them are logged.
+type RecordingElevatorIO struct {
+	mtx   sync.Mutex
+	inner ElevatorIO
+	calls []IOCall
+	// next is where the next call is put once calls is full
+	next   int
+	inputs map[string]interface{}
+}
+
+//NewRecordingElevatorIO records all calls made to inner
+func NewRecordingElevatorIO(inner ElevatorIO) *RecordingElevatorIO {
+	return &RecordingElevatorIO{inner: inner, inputs: make(map[string]interface{})}
+}
+
+//Calls returns the recorded calls, oldest first
+func (r *RecordingElevatorIO) Calls() []IOCall {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	calls := make([]IOCall, 0, len(r.calls))
+	calls = append(calls, r.calls[r.next:]...)
+	return append(calls, r.calls[:r.next]...)
+}
+
+//Reset clears the recorded calls
+func (r *RecordingElevatorIO) Reset() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.calls = nil
+	r.next = 0
+	r.inputs = make(map[string]interface{})
+}
+
+func (r *RecordingElevatorIO) SetMotorDirection(dir Movement) {
+	r.inner.SetMotorDirection(dir)
+	r.record("SetMotorDirection", nil, dir)
+}
+
+func (r *RecordingElevatorIO) SetButtonLamp(button OrderType, floor int, value bool) {
+	r.inner.SetButtonLamp(button, floor, value)
+	r.record("SetButtonLamp", nil, button, floor, value)
+}
+
+func (r *RecordingElevatorIO) SetFloorIndicator(floor int) {
+	r.inner.SetFloorIndicator(floor)
+	r.record("SetFloorIndicator", nil, floor)
+}
+
+func (r *RecordingElevatorIO) SetDoorOpenLamp(value bool) {
+	r.inner.SetDoorOpenLamp(value)
+	r.record("SetDoorOpenLamp", nil, value)
+}
+
+func (r *RecordingElevatorIO) SetStopLamp(value bool) {
+	r.inner.SetStopLamp(value)
+	r.record("SetStopLamp", nil, value)
+}
+
+func (r *RecordingElevatorIO) GetButton(button OrderType, floor int) bool {
+	v := r.inner.GetButton(button, floor)
+	r.recordInput("GetButton", v, button, floor)
+	return v
+}
+
+func (r *RecordingElevatorIO) GetFloor() int {
+	v := r.inner.GetFloor()
+	r.recordInput("GetFloor", v)
+	return v
+}
+
+func (r *RecordingElevatorIO) GetStopButton() bool {
+	v := r.inner.GetStopButton()
+	r.recordInput("GetStopButton", v)
+	return v
+}
+
+func (r *RecordingElevatorIO) GetObstruction() bool {
+	v := r.inner.GetObstruction()
+	r.recordInput("GetObstruction", v)
+	return v
+}
+
+func (r *RecordingElevatorIO) record(name string, result interface{}, args ...interface{}) {
+	call := IOCall{time.Now(), name, args, result}
+	r.mtx.Lock()
+	if len(r.calls) < recordedCalls {
+		r.calls = append(r.calls, call)
+	} else {
+		r.calls[r.next] = call
+		r.next = (r.next + 1) % recordedCalls
+	}
+	r.mtx.Unlock()
+	log.PrintDbg(call)
+}
+
+func (r *RecordingElevatorIO) recordInput(name string, result interface{}, args ...interface{}) {
+	key := fmt.Sprint(name, args)
+	r.mtx.Lock()
+	prev, seen := r.inputs[key]
+	r.inputs[key] = result
+	r.mtx.Unlock()
+	if !seen || prev != result {
+		r.record(name, result, args...)
+	}
+}
@@ -1,63 +1,166 @@
 package eventManager
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"reflect"
+	"sync"
 )
 
-type logSettings map[string]bool
-
 type JsonEvent struct { //Finn ut navn
 	TypeId string
 	JSON   []byte
+	Source string
+}
+
+// publisher is a publishing channel and the source of its events
+type publisher struct {
+	ch     interface{}
+	source string
 }
 
 var JsonPublisherChannel chan interface{}
 var addPublisherChannnel chan interface{}
 var addSubscriberChannel chan interface{}
+var removeSubscriberChannel chan interface{}
+var eventPublisherChannel chan interface{}
+var shutdownChannel chan struct{}
+
+// stopped is closed when the broker has stopped, after this nothing is published
+var stopped chan struct{}
 
-type subscribers map[reflect.Type][]interface{}
+// inFlight counts the events being distributed by the running broker
+var inFlight *sync.WaitGroup
 
-var LogSettings logSettings
+type subscribers map[reflect.Type][]*Subscription
 
-// AddSubscribers add a publisher of an event. 
+// AddPublishers add a publisher of an event. The publisher is removed when the channel is closed.
 func AddPublishers(chans ...interface{}) {
+	AddPublishersFrom(SourceLocal, chans...)
+}
+
+// AddPublishersFrom add publishers of events from source, e.g. SourceDriver. The source is written to recordings.
+func AddPublishersFrom(source string, chans ...interface{}) {
 	for _, ch := range chans {
-		addPublisherChannnel <- ch
+		select {
+		case addPublisherChannnel <- publisher{ch, source}:
+		case <-stopped:
+		}
 	}
 }
 
 // AddSubscribers add a subscribing channel to an event
 func AddSubscribers(chans ...interface{}) {
 	for _, ch := range chans {
-		addSubscriberChannel <- ch
+		AddSubscription(ch)
 	}
 }
 
-// PublishJSON publishes json encoded event. 
+// AddSubscription adds a subscribing channel to an event, and returns the handle used to remove it again
+func AddSubscription(ch interface{}) *Subscription {
+	return AddSubscriptionPolicy(ch, DefaultPolicy)
+}
+
+// AddSubscriptionPolicy adds a subscribing channel with the given delivery policy
+func AddSubscriptionPolicy(ch interface{}, policy DeliveryPolicy) *Subscription {
+	s := newSubscription(ch, policy)
+	select {
+	case addSubscriberChannel <- s:
+	case <-stopped:
+		s.Unsubscribe()
+	}
+	return s
+}
+
+// AddSubscriptionContext adds a subscribing channel that is removed when ctx is done
+func AddSubscriptionContext(ctx context.Context, ch interface{}, policy DeliveryPolicy) *Subscription {
+	s := AddSubscriptionPolicy(ch, policy)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Unsubscribe()
+		case <-s.done:
+		}
+	}()
+	return s
+}
+
+// PublishJSON publishes json encoded event received from the network.
 func PublishJSON(JSON []byte, TypeID string) {
-	d := JsonEvent{TypeID, JSON}
-	JsonPublisherChannel <- d
+	PublishJSONFrom(SourceNetwork, JSON, TypeID)
+}
+
+// PublishJSONFrom publishes json encoded event from source. Used to replay recordings.
+func PublishJSONFrom(source string, JSON []byte, TypeID string) {
+	d := JsonEvent{TypeID, JSON, source}
+	select {
+	case JsonPublisherChannel <- d:
+	case <-stopped:
+	}
+}
+
+// Shutdown stops the broker and waits for the queued events to be delivered, or for ctx to be done. All
+// subscriptions are then removed. Publishing to the event manager after this does nothing.
+// InitEventManager can be called again to restart it.
+func Shutdown(ctx context.Context) error {
+	select {
+	case shutdownChannel <- struct{}{}:
+	case <-stopped:
+	}
+	drained := make(chan struct{})
+	wg := inFlight
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
 
+	registry.Lock()
+	subs := make([]*Subscription, 0, len(registry.subs))
+	for s := range registry.subs {
+		subs = append(subs, s)
+	}
+	registry.Unlock()
+	for _, s := range subs {
+		s.Unsubscribe()
+	}
+	return err
 }
 
 // InitEventManager start the event manager. This has to be called before any publishers or subscribers are added.
-func InitEventManager() {
+// settings maps the name of an event, e.g. "AssignedEvent", to whether that event is logged, "Logging" turns all
+// event logging on or off. See SetEventLogging.
+func InitEventManager(settings map[string]bool) {
+	initEventLogging(settings)
+	registry.Lock()
+	registry.publishers = nil
+	registry.Unlock()
 	addPublisherChannnel = make(chan interface{})
 	addSubscriberChannel = make(chan interface{})
+	removeSubscriberChannel = make(chan interface{})
 	JsonPublisherChannel = make(chan interface{})
-	go broker()
+	eventPublisherChannel = make(chan interface{})
+	shutdownChannel = make(chan struct{})
+	stopped = make(chan struct{})
+	inFlight = &sync.WaitGroup{}
+	go broker(inFlight)
 }
 
-// broker takes incoming events through publisher channels and starts a routine to distribute to all subscriber channels. 
-func broker() {
+// broker takes incoming events through publisher channels and queues them for all subscriber channels.
+func broker(inFlight *sync.WaitGroup) {
 
 	subscribers := make(subscribers)
-	LogSettings = loadLogSettings()
 
-	selectCases := make([]reflect.SelectCase, 3)
+	// the first cases are the channels of the broker itself, the rest are publishers
+	const brokerCases = 6
+	selectCases := make([]reflect.SelectCase, brokerCases)
+	// sources[i] is the source of the publisher in selectCases[i]
+	sources := make([]string, brokerCases)
 
 	selectCases[0] = reflect.SelectCase{
 		Dir:  reflect.SelectRecv,
@@ -74,22 +177,49 @@ func broker() {
 		Chan: reflect.ValueOf(JsonPublisherChannel),
 	}
 
+	selectCases[3] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(eventPublisherChannel),
+	}
+
+	selectCases[4] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(removeSubscriberChannel),
+	}
+
+	selectCases[5] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(shutdownChannel),
+	}
+
 	for {
-		chosen, value, _ := reflect.Select(selectCases)
+		chosen, value, ok := reflect.Select(selectCases)
+		if !ok && chosen >= brokerCases {
+			// publisher channel is closed, remove it
+			removePublisher(chosen - brokerCases)
+			selectCases = append(selectCases[:chosen], selectCases[chosen+1:]...)
+			sources = append(sources[:chosen], sources[chosen+1:]...)
+			continue
+		}
 		switch chosen {
 		case 0:
 			// add publisher
+			pub := value.Interface().(publisher)
 			selectCases = append(selectCases, reflect.SelectCase{
 				Dir:  reflect.SelectRecv,
-				Chan: reflect.ValueOf(value.Interface()),
+				Chan: reflect.ValueOf(pub.ch),
 			})
+			sources = append(sources, pub.source)
+			addPublisher(pub)
 		case 1:
 			// add subscriber
-			typ := value.Elem().Type().Elem()
-			subscribers[typ] = append(subscribers[typ], value.Interface())
+			sub := value.Interface().(*Subscription)
+			typ := sub.ch.Type().Elem()
+			subscribers[typ] = append(subscribers[typ], sub)
 		case 2:
 			// is an published json encoded event, unmarshal and distribute
 			TypeID := value.Elem().Field(0).String()
+			source := value.Elem().Field(2).String()
 			JSON, err := value.Elem().Field(1).Interface().([]byte)
 			if !err { 
 				panic("value not a []byte")
@@ -101,87 +231,44 @@ func broker() {
 
 					v := reflect.New(T)
 					json.Unmarshal([]byte(JSON), v.Interface())
-					subs := subscribers[T]
-					go distribute(v.Elem(), &subs)
+					distribute(v.Elem(), subscribers[T], inFlight, source)
 				}
 			}
 
+		case 3:
+			// is an event published with Publish, distribute
+			value = value.Elem()
+			distribute(value, subscribers[value.Type()], inFlight, SourceLocal)
+		case 4:
+			// remove subscriber
+			sub := value.Interface().(*Subscription)
+			typ := sub.ch.Type().Elem()
+			var subs []*Subscription
+			for _, s := range subscribers[typ] {
+				if s != sub {
+					subs = append(subs, s)
+				}
+			}
+			subscribers[typ] = subs
+		case 5:
+			registry.Lock()
+			registry.publishers = nil
+			registry.Unlock()
+			close(stopped)
+			return
 		default:
 			// is an published event, distribute
-			subs := subscribers[value.Type()]
-			go distribute(value, &subs)
+			distribute(value, subscribers[value.Type()], inFlight, sources[chosen])
 		}
 	}
 }
 
-// distributes to all subscribers
-func distribute(value reflect.Value, s *[]interface{}) {
-	i := value.Interface()
-	logEvent(reflect.Indirect(value), LogSettings)
-	for _, v := range *s {
-		reflect.Select([]reflect.SelectCase{{
-			Dir:  reflect.SelectSend,
-			Chan: reflect.ValueOf(v),
-			Send: reflect.ValueOf(i),
-		}})
-
-	}
-}
-
-// logs events
-func logEvent(evt reflect.Value, settings logSettings) {
-	if !settings["Logging"] {
-		return
+// distributes to all subscribers, by queueing the event for each of them
+func distribute(value reflect.Value, subs []*Subscription, inFlight *sync.WaitGroup, source string) {
+	countPublished(value.Type())
+	logEvent(reflect.Indirect(value))
+	recordEvent(value, source)
+	for _, sub := range subs {
+		sub.enqueue(value, inFlight)
 	}
-
-	if evt.Kind() == reflect.Struct {
-		evtName := evt.Type().Name()
-		if !settings[evtName+"Logging"] {
-			return
-		}
-		fmt.Print(evtName)
-		for i := 1; i < 30-len(evtName); i++ {
-			fmt.Print(" ")
-		}
-		fmt.Print("|")
-		logStruct(evt.Interface())
-		fmt.Print("\n")
-	}
-}
-
-// helper function to log structs
-func logStruct(i interface{}) {
-	s := reflect.ValueOf(i)
-
-	for n := 0; n < s.NumField(); n++ {
-		field := reflect.ValueOf(i).Field(n)
-		fieldType := reflect.TypeOf(i).Field(n)
-		if field.Kind() == reflect.Struct {
-
-			fmt.Print(" ", field.Type().Name(), ": |")
-			logStruct(field.Interface())
-		} else if field.Kind() == reflect.Map {
-			for _, e := range field.MapKeys() {
-				v := field.MapIndex(e)
-				fmt.Print(e, v) // how to get the value?
-			}
-		} else {
-
-			fmt.Print(" ", fieldType.Name, ": ", field, "  ")
-		}
-	}
-
-}
-
-// loads log settings from file
-func loadLogSettings() logSettings {
-	settings := make(logSettings)
-
-	raw, err := ioutil.ReadFile("eventLogSettings.json")
-	if err != nil {
-		panic(err.Error())
-	}
-	json.Unmarshal(raw, &settings)
-
-	return settings
 }
@@ -0,0 +1,124 @@
+package eventManager
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"../log"
+)
+
+// allEvents is the name used for turning all event logging on or off
+const allEvents = "Logging"
+
+// which events are logged, changed at runtime with SetEventLogging
+var eventLog = struct {
+	sync.RWMutex
+	all    bool
+	events map[string]bool
+}{events: make(map[string]bool)}
+
+// eventLogName is the name of an event used by the logging settings. Older config files use "<event name>Logging".
+func eventLogName(name string) string {
+	if name != allEvents {
+		return strings.TrimSuffix(name, "Logging")
+	}
+	return name
+}
+
+func initEventLogging(settings map[string]bool) {
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	eventLog.all = false
+	eventLog.events = make(map[string]bool)
+	for name, on := range settings {
+		if name = eventLogName(name); name == allEvents {
+			eventLog.all = on
+		} else {
+			eventLog.events[name] = on
+		}
+	}
+}
+
+// SetEventLogging turns logging of an event on or off while running. name is the name of the event type without
+// the package, e.g. "AssignedEvent", or "Logging" for all events. An event is logged when both are on. Returns an
+// error if no event type with the name has a publisher or subscriber or has been published.
+func SetEventLogging(name string, on bool) error {
+	name = eventLogName(name)
+	if name != allEvents && !knownEvent(name) {
+		return fmt.Errorf("unknown event %q", name)
+	}
+	eventLog.Lock()
+	defer eventLog.Unlock()
+	if name == allEvents {
+		eventLog.all = on
+	} else {
+		eventLog.events[name] = on
+	}
+	return nil
+}
+
+// knownEvent returns true if an event type with the name is known to the broker
+func knownEvent(name string) bool {
+	for _, e := range Events() {
+		if e.Name == name && (e.Publishers+e.Subscribers > 0 || e.Published > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// EventLogging returns whether event logging is on, and the logging setting of every event that has one
+func EventLogging() (bool, map[string]bool) {
+	eventLog.RLock()
+	defer eventLog.RUnlock()
+	events := make(map[string]bool, len(eventLog.events))
+	for name, on := range eventLog.events {
+		events[name] = on
+	}
+	return eventLog.all, events
+}
+
+func isLogged(name string) bool {
+	eventLog.RLock()
+	defer eventLog.RUnlock()
+	return eventLog.all && eventLog.events[name]
+}
+
+// logs events, through the log package as the "events" module
+func logEvent(evt reflect.Value) {
+	if evt.Kind() != reflect.Struct || !isLogged(evt.Type().Name()) {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-29s|", evt.Type().Name())
+	logStruct(&b, evt.Interface())
+	log.Log(log.INF, "events", b.String())
+}
+
+// helper function to log structs
+func logStruct(b *strings.Builder, i interface{}) {
+	s := reflect.ValueOf(i)
+
+	for n := 0; n < s.NumField(); n++ {
+		field := reflect.ValueOf(i).Field(n)
+		fieldType := reflect.TypeOf(i).Field(n)
+		if field.Kind() == reflect.Struct {
+
+			fmt.Fprint(b, " ", field.Type().Name(), ": |")
+			logStruct(b, field.Interface())
+		} else if field.Kind() == reflect.Map {
+			keys := field.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+			for _, e := range keys {
+				fmt.Fprint(b, " ", e, ": ", field.MapIndex(e))
+			}
+		} else {
+
+			fmt.Fprint(b, " ", fieldType.Name, ": ", field, "  ")
+		}
+	}
+
+}
@@ -0,0 +1,139 @@
+package eventManager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"../log"
+)
+
+// Sources of published events. Events from the driver, the network and the operator interface are the inputs of
+// the elevator, all other events are made by the modules from these, so a recording can be replayed by publishing
+// only the inputs.
+const (
+	SourceLocal    = "local"
+	SourceDriver   = "driver"
+	SourceNetwork  = "network"
+	SourceOperator = "operator"
+)
+
+// recordingVersion is the version of the recording format
+const recordingVersion = 1
+
+// RecordingHeader is the first line of a recording
+type RecordingHeader struct {
+	Version int
+	Start   time.Time
+	// Meta is set by the caller of StartRecording, e.g. the elevator ID and building geometry
+	Meta map[string]int
+}
+
+// Record is one published event in a recording. A recording is the header followed by one record per line.
+type Record struct {
+	Time   time.Time
+	Source string
+	// Type is the type name of the event, as used by PublishJSON
+	Type string
+	// ElevatorID is the ElevatorID field of the event, or -1 if it has none
+	ElevatorID int
+	Event      json.RawMessage
+}
+
+var recorder struct {
+	sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+// StartRecording writes every published event to a new file, until StopRecording is called
+func StartRecording(filename string, meta map[string]int) error {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.file != nil {
+		return fmt.Errorf("already recording to %s", recorder.file.Name())
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(RecordingHeader{recordingVersion, time.Now(), meta}); err != nil {
+		file.Close()
+		return err
+	}
+	recorder.file, recorder.w, recorder.encoder = file, w, encoder
+	return w.Flush()
+}
+
+// StopRecording flushes and closes the recording
+func StopRecording() error {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.file == nil {
+		return nil
+	}
+	err := recorder.w.Flush()
+	if closeErr := recorder.file.Close(); err == nil {
+		err = closeErr
+	}
+	recorder.file, recorder.w, recorder.encoder = nil, nil, nil
+	return err
+}
+
+// recordEvent is called by the broker for every published event
+func recordEvent(evt reflect.Value, source string) {
+	recorder.Lock()
+	defer recorder.Unlock()
+	if recorder.encoder == nil {
+		return
+	}
+	payload, err := json.Marshal(evt.Interface())
+	if err != nil {
+		log.PrintErr("Could not record", evt.Type(), err)
+		return
+	}
+	elevatorID := -1
+	if evt.Kind() == reflect.Struct {
+		if f := evt.FieldByName("ElevatorID"); f.IsValid() && f.Kind() == reflect.Int {
+			elevatorID = int(f.Int())
+		}
+	}
+	recorder.encoder.Encode(Record{time.Now(), source, evt.Type().String(), elevatorID, payload})
+	// flushed for every event, so the recording is complete if the elevator crashes
+	recorder.w.Flush()
+}
+
+// ReadRecording reads a recording made by StartRecording
+func ReadRecording(filename string) (RecordingHeader, []Record, error) {
+	var header RecordingHeader
+	file, err := os.Open(filename)
+	if err != nil {
+		return header, nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if err := decoder.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("%s: header: %v", filename, err)
+	}
+	if header.Version != recordingVersion {
+		return header, nil, fmt.Errorf("%s: unknown recording version %d", filename, header.Version)
+	}
+	var records []Record
+	for decoder.More() {
+		var r Record
+		if err := decoder.Decode(&r); err != nil {
+			// the last record is cut off if the elevator crashed while writing it
+			return header, records, fmt.Errorf("%s: record %d: %v", filename, len(records)+1, err)
+		}
+		records = append(records, r)
+	}
+	return header, records, nil
+}
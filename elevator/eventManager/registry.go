@@ -0,0 +1,114 @@
+package eventManager
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// the subscriptions and publishers known to the broker, for Stats, Events and Registry
+var registry = struct {
+	sync.Mutex
+	subs map[*Subscription]struct{}
+	// publishers are in the same order as the publisher cases of the broker
+	publishers []publisher
+	// published counts the distributed events of each type
+	published map[reflect.Type]uint64
+}{subs: make(map[*Subscription]struct{}), published: make(map[reflect.Type]uint64)}
+
+// PublisherInfo is a publishing channel registered in the broker
+type PublisherInfo struct {
+	// Event is the type name of the events, as used by PublishJSON
+	Event  string
+	Source string
+}
+
+// EventInfo describes an event type known to the broker or to the logging settings
+type EventInfo struct {
+	// Event is the type name of the events, as used by PublishJSON. Empty if the event is only in the logging
+	// settings, e.g. because of a typo.
+	Event string
+	// Name is the name used by SetEventLogging
+	Name        string
+	Logging     bool
+	Publishers  int
+	Subscribers int
+	Published   uint64
+}
+
+// RegistryDump is every publisher and subscription registered in the broker
+type RegistryDump struct {
+	Publishers    []PublisherInfo
+	Subscriptions []SubscriptionStats
+}
+
+func addPublisher(pub publisher) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.publishers = append(registry.publishers, pub)
+}
+
+// removePublisher removes the i-th publisher of the broker
+func removePublisher(i int) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.publishers = append(registry.publishers[:i], registry.publishers[i+1:]...)
+}
+
+func countPublished(typ reflect.Type) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.published[typ]++
+}
+
+// Registry returns every publisher and subscription registered in the broker
+func Registry() RegistryDump {
+	registry.Lock()
+	publishers := make([]PublisherInfo, len(registry.publishers))
+	for i, pub := range registry.publishers {
+		publishers[i] = PublisherInfo{reflect.TypeOf(pub.ch).Elem().String(), pub.source}
+	}
+	registry.Unlock()
+	sort.SliceStable(publishers, func(i, j int) bool { return publishers[i].Event < publishers[j].Event })
+	return RegistryDump{publishers, Stats()}
+}
+
+// Events returns the event types that have a publisher or subscriber, have been published, or are in the logging
+// settings, sorted by name
+func Events() []EventInfo {
+	events := make(map[string]*EventInfo)
+	get := func(typ reflect.Type) *EventInfo {
+		e, exist := events[typ.Name()]
+		if !exist {
+			e = &EventInfo{Event: typ.String(), Name: typ.Name()}
+			events[typ.Name()] = e
+		}
+		return e
+	}
+
+	registry.Lock()
+	for _, pub := range registry.publishers {
+		get(reflect.TypeOf(pub.ch).Elem()).Publishers++
+	}
+	for s := range registry.subs {
+		get(s.ch.Type().Elem()).Subscribers++
+	}
+	for typ, n := range registry.published {
+		get(typ).Published = n
+	}
+	registry.Unlock()
+
+	all, logging := EventLogging()
+	for name := range logging {
+		if _, exist := events[name]; !exist {
+			events[name] = &EventInfo{Name: name}
+		}
+	}
+	list := make([]EventInfo, 0, len(events))
+	for name, e := range events {
+		e.Logging = all && logging[name]
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
@@ -0,0 +1,304 @@
+package eventManager
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeliveryMode decides what happens to an event when the queue of a subscription is full
+type DeliveryMode int
+
+const (
+	// DeliverQueue queues all events, the queue is never full
+	DeliverQueue DeliveryMode = iota
+	// DeliverDropOldest drops the oldest queued event to make room for the new one
+	DeliverDropOldest
+	// DeliverDropNewest drops the new event
+	DeliverDropNewest
+	// DeliverBlockTimeout holds back the broker until there is room, and drops the new event after Timeout
+	DeliverBlockTimeout
+)
+
+func (m DeliveryMode) String() string {
+	switch m {
+	case DeliverQueue:
+		return "queue"
+	case DeliverDropOldest:
+		return "drop-oldest"
+	case DeliverDropNewest:
+		return "drop-newest"
+	case DeliverBlockTimeout:
+		return "block-timeout"
+	}
+	return fmt.Sprintf("DeliveryMode(%d)", int(m))
+}
+
+// MarshalText writes the mode by name, e.g. in the registry dump of the control interface
+func (m DeliveryMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// DeliveryPolicy decides how events are queued for a subscription. Events are always delivered to a
+// subscription in the order they were published, and a slow subscriber only holds back its own events.
+type DeliveryPolicy struct {
+	Mode DeliveryMode
+	// Size is the max number of queued events, not used by DeliverQueue
+	Size int
+	// Timeout is how long DeliverBlockTimeout waits for room in the queue
+	Timeout time.Duration
+}
+
+// DefaultPolicy is used by AddSubscribers, AddSubscription, Subscribe and SubscribeContext. No events are lost.
+var DefaultPolicy = DeliveryPolicy{Mode: DeliverQueue}
+
+func (p DeliveryPolicy) validate() error {
+	switch p.Mode {
+	case DeliverQueue:
+		return nil
+	case DeliverDropOldest, DeliverDropNewest:
+		if p.Size < 1 {
+			return fmt.Errorf("%v needs a size of at least 1, got %d", p.Mode, p.Size)
+		}
+		return nil
+	case DeliverBlockTimeout:
+		if p.Size < 1 || p.Timeout <= 0 {
+			return fmt.Errorf("%v needs a size of at least 1 and a positive timeout, got %d and %v", p.Mode, p.Size, p.Timeout)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown delivery mode %v", p.Mode)
+}
+
+// SubscriptionStats are the delivery counters of a subscription
+type SubscriptionStats struct {
+	// Event is the type name of the events, as used by PublishJSON
+	Event         string
+	Policy        DeliveryPolicy
+	QueueDepth    int
+	MaxQueueDepth int
+	Delivered     uint64
+	Dropped       uint64
+}
+
+// Subscription is a subscribing channel registered in the broker. Events are queued for the subscription by the
+// broker, and sent to the channel by a goroutine of its own.
+type Subscription struct {
+	ch     reflect.Value
+	policy DeliveryPolicy
+	done   chan struct{}
+	once   sync.Once
+
+	mtx   sync.Mutex
+	queue []queuedEvent
+	// wake is signalled when an event is queued, space when an event is taken from the queue
+	wake  chan struct{}
+	space chan struct{}
+	stats SubscriptionStats
+}
+
+type queuedEvent struct {
+	value reflect.Value
+	// inFlight of the broker that queued the event, done when the event is delivered or dropped
+	inFlight *sync.WaitGroup
+}
+
+func newSubscription(ch interface{}, policy DeliveryPolicy) *Subscription {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.SendDir == 0 {
+		panic(fmt.Sprintf("eventManager: subscriber must be a channel that can be sent to, got %T", ch))
+	}
+	if err := policy.validate(); err != nil {
+		panic("eventManager: " + err.Error())
+	}
+	s := &Subscription{
+		ch:     v,
+		policy: policy,
+		done:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		stats:  SubscriptionStats{Event: v.Type().Elem().String(), Policy: policy},
+	}
+	registry.Lock()
+	registry.subs[s] = struct{}{}
+	registry.Unlock()
+	go s.deliver()
+	return s
+}
+
+// Unsubscribe removes the subscription from the broker. Events are not sent to the channel after this returns,
+// queued events are thrown away. The channel is not closed, as an event may be in the middle of being sent to it.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		registry.Lock()
+		delete(registry.subs, s)
+		registry.Unlock()
+		select {
+		case removeSubscriberChannel <- s:
+		case <-stopped:
+		}
+	})
+}
+
+// Done is closed when the subscription is removed
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stats returns the delivery counters of the subscription
+func (s *Subscription) Stats() SubscriptionStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.stats
+}
+
+// Stats returns the delivery counters of all subscriptions, sorted by event name
+func Stats() []SubscriptionStats {
+	registry.Lock()
+	subs := make([]*Subscription, 0, len(registry.subs))
+	for s := range registry.subs {
+		subs = append(subs, s)
+	}
+	registry.Unlock()
+
+	stats := make([]SubscriptionStats, len(subs))
+	for i, s := range subs {
+		stats[i] = s.Stats()
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Event < stats[j].Event })
+	return stats
+}
+
+// enqueue is called by the broker to queue an event according to the policy of the subscription
+func (s *Subscription) enqueue(value reflect.Value, inFlight *sync.WaitGroup) {
+	s.mtx.Lock()
+	// a removed subscription is no longer delivered, so an event queued now would never be done
+	if s.removed() {
+		s.mtx.Unlock()
+		return
+	}
+	if s.policy.Mode != DeliverQueue && len(s.queue) >= s.policy.Size {
+		switch s.policy.Mode {
+		case DeliverDropNewest:
+			s.stats.Dropped++
+			s.mtx.Unlock()
+			return
+		case DeliverDropOldest:
+			s.queue[0].inFlight.Done()
+			s.queue = s.queue[1:]
+			s.stats.Dropped++
+		case DeliverBlockTimeout:
+			s.mtx.Unlock()
+			if !s.waitForSpace() {
+				s.mtx.Lock()
+				s.stats.Dropped++
+				s.mtx.Unlock()
+				return
+			}
+			s.mtx.Lock()
+			if s.removed() {
+				s.mtx.Unlock()
+				return
+			}
+		}
+	}
+	inFlight.Add(1)
+	s.queue = append(s.queue, queuedEvent{value, inFlight})
+	s.stats.QueueDepth = len(s.queue)
+	if s.stats.QueueDepth > s.stats.MaxQueueDepth {
+		s.stats.MaxQueueDepth = s.stats.QueueDepth
+	}
+	s.mtx.Unlock()
+	signal(s.wake)
+}
+
+// waitForSpace waits until the queue is not full. Returns false on timeout or if the subscription is removed.
+func (s *Subscription) waitForSpace() bool {
+	timer := time.NewTimer(s.policy.Timeout)
+	defer timer.Stop()
+	for {
+		s.mtx.Lock()
+		full := len(s.queue) >= s.policy.Size
+		s.mtx.Unlock()
+		if !full {
+			return true
+		}
+		select {
+		case <-s.space:
+		case <-timer.C:
+			return false
+		case <-s.done:
+			return false
+		}
+	}
+}
+
+// deliver sends the queued events to the channel of the subscription, one at a time
+func (s *Subscription) deliver() {
+	for {
+		s.mtx.Lock()
+		if len(s.queue) == 0 {
+			s.mtx.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				s.discard()
+				return
+			}
+		}
+		evt := s.queue[0]
+		s.queue = s.queue[1:]
+		s.stats.QueueDepth = len(s.queue)
+		s.mtx.Unlock()
+		signal(s.space)
+
+		chosen, _, _ := reflect.Select([]reflect.SelectCase{{
+			Dir:  reflect.SelectSend,
+			Chan: s.ch,
+			Send: evt.value,
+		}, {
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(s.done),
+		}})
+		evt.inFlight.Done()
+		if chosen == 1 {
+			s.discard()
+			return
+		}
+		s.mtx.Lock()
+		s.stats.Delivered++
+		s.mtx.Unlock()
+	}
+}
+
+// removed returns true if the subscription has been removed
+func (s *Subscription) removed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// discard throws away the queued events of a removed subscription
+func (s *Subscription) discard() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, evt := range s.queue {
+		evt.inFlight.Done()
+	}
+	s.queue = nil
+	s.stats.QueueDepth = 0
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,85 @@
+package eventManager
+
+import (
+	"context"
+	"reflect"
+)
+
+// Topic is a typed handle to the events of type T. It uses the same broker as AddPublishers and AddSubscribers,
+// so events published through a Topic reach subscribers added with AddSubscribers and the other way around.
+type Topic[T any] struct{}
+
+// NewTopic returns the topic of events of type T
+func NewTopic[T any]() Topic[T] {
+	return Topic[T]{}
+}
+
+// Subscribe returns a new channel receiving all events of type T
+func (Topic[T]) Subscribe() <-chan T {
+	return Subscribe[T]()
+}
+
+// SubscribeContext returns a new channel receiving all events of type T until ctx is done or the subscription is removed
+func (Topic[T]) SubscribeContext(ctx context.Context) (<-chan T, *Subscription) {
+	return SubscribeContext[T](ctx)
+}
+
+// SubscribeWith returns a new channel receiving all events of type T with the given delivery policy, until ctx
+// is done or the subscription is removed
+func (Topic[T]) SubscribeWith(ctx context.Context, policy DeliveryPolicy) (<-chan T, *Subscription) {
+	return SubscribeWith[T](ctx, policy)
+}
+
+// Publisher returns a new channel publishing events of type T
+func (Topic[T]) Publisher() chan<- T {
+	return Publisher[T]()
+}
+
+// Publish publishes one event of type T
+func (Topic[T]) Publish(evt T) {
+	Publish(evt)
+}
+
+// Name is the type name used for T by PublishJSON
+func (Topic[T]) Name() string {
+	return TypeName[T]()
+}
+
+// Subscribe returns a new channel receiving all events of type T
+func Subscribe[T any]() <-chan T {
+	ch := make(chan T)
+	AddSubscribers(ch)
+	return ch
+}
+
+// SubscribeContext returns a new channel receiving all events of type T until ctx is done or the subscription is removed
+func SubscribeContext[T any](ctx context.Context) (<-chan T, *Subscription) {
+	return SubscribeWith[T](ctx, DefaultPolicy)
+}
+
+// SubscribeWith returns a new channel receiving all events of type T with the given delivery policy, until ctx
+// is done or the subscription is removed
+func SubscribeWith[T any](ctx context.Context, policy DeliveryPolicy) (<-chan T, *Subscription) {
+	ch := make(chan T)
+	return ch, AddSubscriptionContext(ctx, ch, policy)
+}
+
+// Publisher returns a new channel publishing events of type T. Close it to remove the publisher.
+func Publisher[T any]() chan<- T {
+	ch := make(chan T)
+	AddPublishers(ch)
+	return ch
+}
+
+// Publish publishes one event of type T, without the need for a publisher channel
+func Publish[T any](evt T) {
+	select {
+	case eventPublisherChannel <- evt:
+	case <-stopped:
+	}
+}
+
+// TypeName is the name of T as used by PublishJSON, e.g. "elevator.NewOrderEvent"
+func TypeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
@@ -4,10 +4,11 @@ import (
 	"./utils"
 )
 
-//OrderCompleteEvent I used to signal when an Order is completed
+//OrderCompleteEvent I used to signal when an Order is completed. TraceIDs are the traces of the orders completed.
 type OrderCompleteEvent struct {
 	ElevatorID int
 	Floor      int
+	TraceIDs   []string
 }
 
 //ElevatorControlEvent is used to signal elevator control commands
@@ -17,13 +18,17 @@ type ElevatorCtrlEvent struct {
 	Movement  Movement
 }
 
-//CostResultEvent is used to signal a result of a cost calculation
+//CostResultEvent is used to signal a result of a cost calculation. ElevatorFloor and QueueLength are the floor
+//and number of active orders of the elevator, used by some assignment strategies
 type CostResultEvent struct {
-	ElevatorID int
-	OrderID    int
-	Score      int
-	Floor      int
-	OrderType  OrderType
+	ElevatorID    int
+	OrderID       int
+	Score         int
+	Floor         int
+	OrderType     OrderType
+	TraceID       string
+	ElevatorFloor int
+	QueueLength   int
 }
 
 //FloorUptEvent happens everytime elevator reaches a new floor
@@ -40,12 +45,14 @@ const (
 	orderCab
 )
 
-//NewOrderEvent happens everytime there is a new panel order
+//NewOrderEvent happens everytime there is a new panel order. TraceID follows the order through all modules and
+//elevators until it is completed, also when it is given a new OrderID
 type NewOrderEvent struct {
 	ElevatorID int
 	Floor      int
 	OrderID    int
 	OrderType  OrderType
+	TraceID    string
 }
 
 //NewCabOrderEvent happens everytime there is a new cab order
@@ -54,6 +61,7 @@ type NewCabOrderEvent struct {
 	Floor      int
 	OrderID    int
 	OrderType  OrderType
+	TraceID    string
 }
 
 //ObstructedEvent happens everytime the elevator is obstructed or the obstruction goes away
@@ -62,6 +70,14 @@ type ObstructedEvent struct {
 	Obstructed bool
 }
 
+//StopButtonEvent happens everytime the stop button is pressed or released. AtFloor tells if the elevator
+//was at a floor when the button changed
+type StopButtonEvent struct {
+	ElevatorID int
+	Stopped    bool
+	AtFloor    bool
+}
+
 //Assigned Event is used to signal that an eevator has been selected for an order
 type AssignedEvent struct {
 	ElevatorID int
@@ -69,6 +85,7 @@ type AssignedEvent struct {
 	Floor      int
 	OrderType  OrderType
 	SingleMode bool
+	TraceID    string
 }
 
 //CheckAssignedElevEvent is used to send the assigned elevator to the other elevator for comparison
@@ -78,6 +95,7 @@ type CheckAssignedElevEvent struct {
 	OrderID            int
 	Floor              int
 	OrderType          OrderType
+	TraceID            string
 }
 
 //AssignedOKEvent is used to check if the assigned elevator is the same for all elevators
@@ -87,6 +105,7 @@ type AssignedOKEvent struct {
 	OrderID            int
 	Floor              int
 	OrderType          OrderType
+	TraceID            string
 }
 
 //Elevator Availability event used to signal that the availability of an elevator has changed
@@ -101,6 +120,23 @@ type ConnectionEvent struct {
 	Connect    bool
 }
 
+//PartitionEvent is published by the connection check when the elevators this elevator can reach change.
+//Members are the reachable elevators and this one, sorted by ID. Epoch is higher than the epoch of any partition
+//they were in before. A higher epoch started by another member at the same time is taken over without a new
+//PartitionEvent, so the elevators of a partition end up agreeing on the epoch they send in their check packets.
+type PartitionEvent struct {
+	ElevatorID int
+	Epoch      int
+	Members    []int
+}
+
+//EpochEvent is published by the connection check when it takes over a higher epoch from another member of
+//its partition, so the epoch sent in a RejoinStateEvent is the one sent in the check packets
+type EpochEvent struct {
+	ElevatorID int
+	Epoch      int
+}
+
 //OrderLampCtrEvent is used to signal if the cab order lights or hall order lighst should turn on
 type OrderLampCtrEvent struct {
 	Floor     int
@@ -122,7 +158,46 @@ type ActiveOrdersReqEvent struct {
 //ActiveOrderAnsEvent return the requested elevators active orders
 type ActiveOrdersAnsEvent struct {
 	ElevatorID   int
-	ActiveOrders [utils.FLOOR_NUM][utils.ORDER_TYPE_NUM - 1]int
+	ActiveOrders [][utils.ORDER_TYPE_NUM - 1]int
+	TraceIDs     [][utils.ORDER_TYPE_NUM - 1]string
+}
+
+//HallRequestTableEvent is sent periodically with the elevators replicated table of outstanding hall calls
+type HallRequestTableEvent struct {
+	ElevatorID int
+	Counters   [][utils.ORDER_TYPE_NUM - 1]int
+}
+
+//RejoinStateEvent is sent to an elevator that has just connected, with the senders availability,
+//its view of the hall orders assigned to every elevator and its copy of the connecting elevators cab orders.
+//The partition the sender was in before the connect, its hall request table and its copies of the cab orders
+//of all elevators are used to merge the two sides when a network partition heals. TraceIDs are the trace IDs
+//of the hall calls, passed on with the orders the connecting elevator takes.
+type RejoinStateEvent struct {
+	ElevatorID int
+	TargetID   int
+	Available  bool
+	HallOrders map[int][][utils.ORDER_TYPE_NUM - 1]int
+	CabOrders  []int
+	Epoch      int
+	Members    []int
+	Counters   [][utils.ORDER_TYPE_NUM - 1]int
+	CabBackups map[int][]int
+	TraceIDs   [][utils.ORDER_TYPE_NUM - 1]string
+}
+
+//CabOrdersBackupEvent is sent everytime the cab orders of an elevator change, so the other elevators
+//can hold a copy and give it back if the elevator loses its own
+type CabOrdersBackupEvent struct {
+	ElevatorID int
+	CabOrders  []int
+}
+
+//RejoinDoneEvent is sent when an elevator has merged the RejoinStateEvent from TargetID. TargetID starts
+//counting the elevator in order assignment when it receives this
+type RejoinDoneEvent struct {
+	ElevatorID int
+	TargetID   int
 }
 
 //UnavailableOrdersHandledEvent is used to signal that the unavailable elevators hall orders
@@ -131,3 +206,122 @@ type UnavailableOrdersHandledEvent struct {
 	ElevatorID int
 	Handled    bool
 }
+
+//LostOrdersHandledEvent is sent by the leader when it has given out the hall orders of the lost elevator LostID,
+//so the other elevators forget them too and a later leader does not give them out again
+type LostOrdersHandledEvent struct {
+	ElevatorID int
+	LostID     int
+}
+
+//HeartbeatEvent is sent periodically by the heartbeat module to itself. It is only passed on to the supervisor
+//when it makes it through the event manager, so a stalled event manager stops the heartbeats
+type HeartbeatEvent struct {
+	ElevatorID int
+}
+
+//ElevatorStateEvent is sent when the state of an elevator changes, and every Network.StateInterval so elevators
+//that connect later also get it. ActiveOrders are the hall orders assigned to the elevator and its cab orders
+type ElevatorStateEvent struct {
+	ElevatorID   int
+	Floor        int
+	Behaviour    ElevatorBehaviour
+	Movement     Movement
+	Available    bool
+	ActiveOrders [][utils.ORDER_TYPE_NUM]int
+}
+
+//CancelOrderEvent removes an outstanding order, sent by the operator interface. Hall calls are removed on all
+//elevators, cab orders only on ElevatorID
+type CancelOrderEvent struct {
+	ElevatorID int
+	Floor      int
+	OrderType  OrderType
+}
+
+//ServiceModeEvent takes the elevator out of service or puts it back. An elevator out of service is unavailable,
+//so its hall orders are given to the others, but it still serves its cab orders
+type ServiceModeEvent struct {
+	ElevatorID int
+	InService  bool
+}
+
+//HallAssignmentInput is what the optimal hall assignment is computed from: the outstanding hall calls, and the
+//state of each available elevator by increasing ID with only its cab orders in ActiveOrders
+type HallAssignmentInput struct {
+	HallCalls [][utils.ORDER_TYPE_NUM - 1]bool
+	States    []ElevatorStateEvent
+}
+
+//HallAssignmentEvent is sent by the coordinator in the optimal assignment mode with the hall calls given to each
+//elevator. Every elevator computes the assignment again from Input, and only uses it if it gets the same.
+type HallAssignmentEvent struct {
+	ElevatorID int
+	Round      int
+	InputHash  uint64
+	Input      HallAssignmentInput
+	Assignment map[int][][utils.ORDER_TYPE_NUM - 1]bool
+}
+
+//OrderRevokedEvent takes a hall order from an elevator, when an optimal assignment round has given it to another
+type OrderRevokedEvent struct {
+	ElevatorID int
+	Floor      int
+	OrderType  OrderType
+}
+
+//HandoverProposeEvent is sent by the coordinator when another elevator would serve a hall order of From sooner.
+//From and To answer with a HandoverAnswerEvent.
+type HandoverProposeEvent struct {
+	ElevatorID int
+	HandoverID int
+	From       int
+	To         int
+	Floor      int
+	OrderType  OrderType
+}
+
+//HandoverAnswerEvent tells the coordinator if the elevator accepts the handover
+type HandoverAnswerEvent struct {
+	ElevatorID int
+	HandoverID int
+	Accept     bool
+}
+
+//HandoverCommitEvent is sent by the coordinator when both elevators have accepted a handover. Every elevator then
+//moves the hall order from From to To, if From still has it.
+type HandoverCommitEvent struct {
+	ElevatorID int
+	HandoverID int
+	From       int
+	To         int
+	Floor      int
+	OrderType  OrderType
+}
+
+//ElectionEvent starts a leader election. Elevators with a lower ID answer with an ElectionAnswerEvent and start
+//their own election.
+type ElectionEvent struct {
+	ElevatorID int
+	Term       int
+}
+
+//ElectionAnswerEvent tells TargetID that an elevator with a lower ID is alive and takes over the election
+type ElectionAnswerEvent struct {
+	ElevatorID int
+	TargetID   int
+	Term       int
+}
+
+//LeaderEvent is sent by the leader when it has won an election, and every Network.LeaderInterval after that
+type LeaderEvent struct {
+	ElevatorID int
+	Term       int
+}
+
+//LeaderChangedEvent is published by the election module of this elevator when it has a new leader
+type LeaderChangedEvent struct {
+	ElevatorID int
+	LeaderID   int
+	Term       int
+}
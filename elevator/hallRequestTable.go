@@ -0,0 +1,100 @@
+package elevator
+
+import (
+	"./utils"
+)
+
+//hallRequestTable is the replicated table of outstanding hall calls. Every call has a counter that is odd
+//while the call is outstanding and even when it is not. A button press increments an even counter and a
+//completed order increments an odd counter. Tables from other elevators are merged by taking the max of
+//each counter, so all elevators converge on the same set of outstanding hall calls.
+type hallRequestTable struct {
+	counters [][utils.ORDER_TYPE_NUM - 1]int
+	// last table received from each connected elevator
+	peers map[int][][utils.ORDER_TYPE_NUM - 1]int
+}
+
+func newHallRequestTable() *hallRequestTable {
+	return &hallRequestTable{
+		counters: make([][utils.ORDER_TYPE_NUM - 1]int, utils.FLOOR_NUM),
+		peers:    make(map[int][][utils.ORDER_TYPE_NUM - 1]int),
+	}
+}
+
+//press registers a new hall call. Returns true if the table changed.
+func (t *hallRequestTable) press(floor int, orderType OrderType) bool {
+	if t.counters[floor][orderType]%2 == 0 {
+		t.counters[floor][orderType]++
+		return true
+	}
+	return false
+}
+
+//complete registers that all hall calls on a floor are served. Returns true if the table changed.
+func (t *hallRequestTable) complete(floor int) bool {
+	changed := false
+	for orderType := range t.counters[floor] {
+		if t.counters[floor][orderType]%2 == 1 {
+			t.counters[floor][orderType]++
+			changed = true
+		}
+	}
+	return changed
+}
+
+//cancel removes a hall call without it being served. Returns true if the table changed.
+func (t *hallRequestTable) cancel(floor int, orderType OrderType) bool {
+	if t.counters[floor][orderType]%2 == 1 {
+		t.counters[floor][orderType]++
+		return true
+	}
+	return false
+}
+
+//merge takes in the table of another elevator. Returns true if this table changed.
+func (t *hallRequestTable) merge(elevatorID int, counters [][utils.ORDER_TYPE_NUM - 1]int) bool {
+	if len(counters) != utils.FLOOR_NUM {
+		return false
+	}
+	t.peers[elevatorID] = counters
+	changed := false
+	for floor := range counters {
+		for orderType, v := range counters[floor] {
+			if v > t.counters[floor][orderType] {
+				t.counters[floor][orderType] = v
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+//forget removes the last table received from an elevator, used when it disconnects
+func (t *hallRequestTable) forget(elevatorID int) {
+	delete(t.peers, elevatorID)
+}
+
+func (t *hallRequestTable) outstanding(floor int, orderType OrderType) bool {
+	return t.counters[floor][orderType]%2 == 1
+}
+
+//confirmed returns true if the hall call is outstanding and all connected elevators agree on it
+func (t *hallRequestTable) confirmed(floor int, orderType OrderType, connected map[int]bool) bool {
+	if !t.outstanding(floor, orderType) {
+		return false
+	}
+	for elevatorID := range connected {
+		peer, exist := t.peers[elevatorID]
+		if !exist || peer[floor][orderType] != t.counters[floor][orderType] {
+			return false
+		}
+	}
+	return true
+}
+
+//snapshot returns a copy of the counters that can be sent to the other elevators
+func (t *hallRequestTable) snapshot() [][utils.ORDER_TYPE_NUM - 1]int {
+	counters := make([][utils.ORDER_TYPE_NUM - 1]int, len(t.counters))
+	copy(counters, t.counters)
+	return counters
+}
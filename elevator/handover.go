@@ -0,0 +1,127 @@
+package elevator
+
+import (
+	"./utils"
+)
+
+//handoverCoordinator keeps the handovers proposed by this elevator until both elevators have answered. It is only
+//used from the assigner module.
+type handoverCoordinator struct {
+	counter int
+	pending map[int]*pendingHandover
+}
+
+type pendingHandover struct {
+	proposal     HandoverProposeEvent
+	fromAccepted bool
+	toAccepted   bool
+}
+
+func newHandoverCoordinator() *handoverCoordinator {
+	return &handoverCoordinator{pending: make(map[int]*pendingHandover)}
+}
+
+//propose looks for hall orders that another available elevator would serve at least Assignment.ReassignMargin
+//sooner than the elevator that has them, from the last state of each elevator. Orders that more than one
+//elevator has, or that are already being handed over, are left alone.
+func (h *handoverCoordinator) propose(active []int, states map[int]ElevatorStateEvent) []HandoverProposeEvent {
+	var available []ElevatorStateEvent
+	for _, id := range active {
+		state, known := states[id]
+		if known && state.Available && checkAssignmentState(state) == nil {
+			available = append(available, state)
+		}
+	}
+	busy := make(map[hallCall]bool)
+	for _, p := range h.pending {
+		busy[hallCall{p.proposal.Floor, p.proposal.OrderType}] = true
+	}
+
+	margin := int(utils.Config.Assignment.ReassignMargin.Milliseconds())
+	var proposals []HandoverProposeEvent
+	for floor := 0; floor < utils.FLOOR_NUM; floor++ {
+		for _, orderType := range []OrderType{orderHallUp, orderHallDown} {
+			var holders []ElevatorStateEvent
+			for _, state := range available {
+				if state.ActiveOrders[floor][orderType] == 1 {
+					holders = append(holders, state)
+				}
+			}
+			if len(holders) != 1 || busy[hallCall{floor, orderType}] {
+				continue
+			}
+			from := holders[0]
+			fromCost := TimeToServeOrder(from.elevatorState(), orderType, floor)
+			to, toCost := -1, 0
+			for _, state := range available {
+				if state.ElevatorID == from.ElevatorID {
+					continue
+				}
+				if cost := TimeToServeOrder(state.elevatorState(), orderType, floor); to == -1 || cost < toCost {
+					to, toCost = state.ElevatorID, cost
+				}
+			}
+			if to == -1 || toCost+margin >= fromCost {
+				continue
+			}
+			h.counter++
+			p := HandoverProposeEvent{utils.ELEVATOR_ID, (utils.ELEVATOR_ID << 16) + (h.counter & 0xffff), from.ElevatorID, to, floor, orderType}
+			h.pending[p.HandoverID] = &pendingHandover{proposal: p}
+			proposals = append(proposals, p)
+		}
+	}
+	return proposals
+}
+
+//answer registers an answer to a handover proposed by this elevator. Returns true when the handover is done,
+//with committed set if both elevators accepted it.
+func (h *handoverCoordinator) answer(evt HandoverAnswerEvent) (commit HandoverCommitEvent, committed bool, done bool) {
+	pending, exist := h.pending[evt.HandoverID]
+	if !exist {
+		return commit, false, false
+	}
+	p := pending.proposal
+	if !evt.Accept {
+		delete(h.pending, evt.HandoverID)
+		handovers.Inc("rejected")
+		return commit, false, true
+	}
+	switch evt.ElevatorID {
+	case p.From:
+		pending.fromAccepted = true
+	case p.To:
+		pending.toAccepted = true
+	}
+	if !pending.fromAccepted || !pending.toAccepted {
+		return commit, false, false
+	}
+	delete(h.pending, evt.HandoverID)
+	handovers.Inc("committed")
+	return HandoverCommitEvent{utils.ELEVATOR_ID, p.HandoverID, p.From, p.To, p.Floor, p.OrderType}, true, true
+}
+
+//expire drops a handover that has not been answered by both elevators in time
+func (h *handoverCoordinator) expire(handoverID int) {
+	if _, exist := h.pending[handoverID]; exist {
+		delete(h.pending, handoverID)
+		handovers.Inc("expired")
+	}
+}
+
+//handoverAnswer decides if this elevator accepts a proposed handover, from its own last state. The elevator giving
+//the order away must still have it and not be serving it right now, the elevator taking it must be available.
+func handoverAnswer(evt HandoverProposeEvent, own ElevatorStateEvent, known bool) bool {
+	if !validHallOrder(evt.Floor, evt.OrderType) || !known || !own.Available || len(own.ActiveOrders) != utils.FLOOR_NUM {
+		return false
+	}
+	if own.ElevatorID == evt.From {
+		serving := own.Floor == evt.Floor && (own.Behaviour == behaviourDoorOpen || own.Behaviour == behaviourObstructed)
+		return own.ActiveOrders[evt.Floor][evt.OrderType] == 1 && !serving
+	}
+	return true
+}
+
+//validHallOrder checks the hall order of a handover received from the network
+func validHallOrder(floor int, orderType OrderType) bool {
+	return floor >= 0 && floor < utils.FLOOR_NUM && (orderType == orderHallUp || orderType == orderHallDown)
+}
@@ -0,0 +1,38 @@
+package elevator
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"./eventManager"
+	"./log"
+	"./utils"
+)
+
+//HeartbeatModule tells the supervisor that the elevator is alive. Does nothing if the elevator is not started by a supervisor.
+func HeartbeatModule() {
+	if utils.HEARTBEAT_PORT == 0 {
+		return
+	}
+	conn, err := net.Dial("udp", "127.0.0.1:"+strconv.Itoa(utils.HEARTBEAT_PORT))
+	utils.CheckError(err)
+	defer conn.Close()
+
+	//Only the latest heartbeat matters, so they are not queued up if sending them to the supervisor is slow
+	heartbeatSub, _ := eventManager.SubscribeWith[HeartbeatEvent](context.Background(),
+		eventManager.DeliveryPolicy{Mode: eventManager.DeliverDropOldest, Size: 1})
+
+	ticker := time.NewTicker(utils.Config.Supervisor.HeartbeatInterval.Duration)
+	for {
+		select {
+		case <-ticker.C:
+			eventManager.Publish(HeartbeatEvent{utils.ELEVATOR_ID})
+		case <-heartbeatSub:
+			if _, err := conn.Write([]byte(strconv.Itoa(utils.ELEVATOR_ID))); err != nil {
+				log.PrintErr("Could not send heartbeat:", err)
+			}
+		}
+	}
+}
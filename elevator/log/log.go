@@ -1,99 +1,269 @@
 package log
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"io"
+	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-type logSettings map[string]string
+//Level is the severity of a log line. A module prints the lines at or above its level.
+type Level int
 
-var LogSettings logSettings
-var ch chan string
+const (
+	DBG Level = iota
+	INF
+	WRN
+	ERR
+)
 
-func Init() {
-	LogSettings = loadLogSettings()
-	ch = make(chan string)
-	go printer()
+func (l Level) String() string {
+	switch l {
+	case DBG:
+		return "DBG"
+	case INF:
+		return "INF"
+	case WRN:
+		return "WRN"
+	case ERR:
+		return "ERR"
+	}
+	return "L" + strconv.Itoa(int(l))
 }
 
-func PrintDbg(s ...interface{}) {
-	fileName, _ := getFilename()
-	setting := LogSettings[fileName+"Logging"]
-	if setting == "DBG" {
-		print(fileName, "DBG", s)
+//ParseLevel parses DBG, INF, WRN or ERR
+func ParseLevel(s string) (Level, error) {
+	for l := DBG; l <= ERR; l++ {
+		if s == l.String() {
+			return l, nil
+		}
 	}
+	return INF, fmt.Errorf("unknown log level %q, must be DBG, INF, WRN or ERR", s)
+}
+
+//Formats of the log lines
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
 
+//Settings of the logger
+type Settings struct {
+	// Level is used for modules not in Modules
+	Level Level
+	// Modules maps the name of a module file without .go, e.g. "controller", to its level
+	Modules map[string]Level
+	// Format is FormatText, FormatJSON or FormatLogfmt
+	Format string
+	// Stdout turns printing to stdout on or off
+	Stdout bool
+	// File is written to if set. It is rotated when it is larger than MaxFileSize, keeping MaxFiles old files.
+	File        string
+	MaxFileSize int64
+	MaxFiles    int
+	// ElevatorID is written on every line, so logs from several elevators can be put together
+	ElevatorID int
 }
 
-func PrintErr(s ...interface{}) {
-	fileName, _ := getFilename()
-	setting := LogSettings[fileName+"Logging"]
-	if setting == "INF" || setting == "ERR" {
-		print(fileName, "ERR", s)
+var logger = struct {
+	sync.Mutex
+	level      Level
+	modules    map[string]Level
+	format     string
+	elevatorID int
+	sinks      []io.Writer
+	file       *rotatingFile
+}{level: INF, modules: map[string]Level{}, format: FormatText, sinks: []io.Writer{os.Stdout}}
+
+//Init sets up the logger. Until it is called INF and above is printed to stdout.
+func Init(settings Settings) error {
+	var file *rotatingFile
+	if settings.File != "" {
+		var err error
+		if file, err = openRotatingFile(settings.File, settings.MaxFileSize, settings.MaxFiles); err != nil {
+			return err
+		}
 	}
-}
 
-func PrintInf(s ...interface{}) {
-	fileName, _ := getFilename()
-	setting := LogSettings[fileName+"Logging"]
-	if setting == "DBG" || setting == "INF" || setting == "ERR" {
-		print(fileName, "INF", s)
+	logger.Lock()
+	defer logger.Unlock()
+	if logger.file != nil {
+		logger.file.Close()
+	}
+	logger.level = settings.Level
+	logger.modules = make(map[string]Level)
+	for module, level := range settings.Modules {
+		logger.modules[module] = level
 	}
+	logger.format = settings.Format
+	logger.elevatorID = settings.ElevatorID
+	logger.sinks = nil
+	if settings.Stdout {
+		logger.sinks = append(logger.sinks, os.Stdout)
+	}
+	logger.file = file
+	if file != nil {
+		logger.sinks = append(logger.sinks, file)
+	}
+	return nil
 }
 
-func print(fileName, setting string, s ...interface{}) {
-	var prefix bytes.Buffer
+//SetLevel changes the level of a module while running
+func SetLevel(module string, level Level) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.modules[module] = level
+}
 
-	prefix.WriteString(fileName)
+//SetDefaultLevel changes the level of the modules without a level of their own
+func SetDefaultLevel(level Level) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.level = level
+}
 
-	for i := 1; i < 24-len(fileName); i++ {
-		prefix.WriteString(" ")
+//Levels returns the default level and the level of every module set in the settings or with SetLevel
+func Levels() (Level, map[string]Level) {
+	logger.Lock()
+	defer logger.Unlock()
+	modules := make(map[string]Level, len(logger.modules))
+	for module, level := range logger.modules {
+		modules[module] = level
 	}
-	prefix.WriteString(setting + "  |")
-	t := append([]interface{}{prefix.String()}, s...)
+	return logger.level, modules
+}
 
-	ch <- fmt.Sprint(t)
+func PrintDbg(s ...interface{}) {
+	Log(DBG, caller(), s...)
+}
 
+func PrintInf(s ...interface{}) {
+	Log(INF, caller(), s...)
 }
 
-func loadLogSettings() logSettings {
-	settings := make(logSettings)
+func PrintWrn(s ...interface{}) {
+	Log(WRN, caller(), s...)
+}
 
-	raw, err := ioutil.ReadFile("moduleLogSettings.json")
-	if err != nil {
-		panic(err.Error())
+func PrintErr(s ...interface{}) {
+	Log(ERR, caller(), s...)
+}
+
+//Log writes a line for a module, if the level of the module lets it through
+func Log(level Level, module string, s ...interface{}) {
+	now := time.Now()
+	logger.Lock()
+	defer logger.Unlock()
+	moduleLevel, exist := logger.modules[module]
+	if !exist {
+		moduleLevel = logger.level
+	}
+	if level < moduleLevel || len(logger.sinks) == 0 {
+		return
+	}
+	msg := strings.TrimSuffix(fmt.Sprintln(s...), "\n")
+	line := format(logger.format, now, level, logger.elevatorID, module, msg)
+	for _, sink := range logger.sinks {
+		sink.Write(line)
 	}
-	json.Unmarshal(raw, &settings)
+}
 
-	return settings
+func format(f string, t time.Time, level Level, elevatorID int, module, msg string) []byte {
+	switch f {
+	case FormatJSON:
+		line, _ := json.Marshal(struct {
+			Time     string `json:"time"`
+			Level    string `json:"level"`
+			Elevator int    `json:"elevator"`
+			Module   string `json:"module"`
+			Msg      string `json:"msg"`
+		}{t.Format(time.RFC3339Nano), level.String(), elevatorID, module, msg})
+		return append(line, '\n')
+	case FormatLogfmt:
+		return []byte(fmt.Sprintf("time=%s level=%s elevator=%d module=%s msg=%s\n",
+			t.Format(time.RFC3339Nano), level, elevatorID, logfmtValue(module), logfmtValue(msg)))
+	}
+	return []byte(fmt.Sprintf("%s %s %d %-22s | %s\n", t.Format("15:04:05.000"), level, elevatorID, module, msg))
 }
 
-func printer() {
-	for s := range ch {
-		fmt.Println(s)
+func logfmtValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, " =\"\t\n") {
+		return s
 	}
+	return strconv.Quote(s)
 }
 
-func getFilename() (string, error) {
-	var ok bool
+//caller returns the module of the function calling the Print function, the file name without .go
+func caller() string {
 	_, fileName, _, ok := runtime.Caller(2)
 	if !ok {
-		return "", fmt.Errorf("N/A")
-	}
-	nd, nf := filepath.Split(fileName)
-	fileName = filepath.Join(filepath.Base(nd), nf)
-	deleteString := ""
-	for _, v := range fileName {
-		deleteString = deleteString + string(v)
-		if string(v) == "\\" {
-			fileName = strings.ReplaceAll(fileName, deleteString, "")
+		return "unknown"
+	}
+	// the path may use either separator, depending on where the program was built
+	fileName = fileName[strings.LastIndexAny(fileName, `/\`)+1:]
+	return strings.TrimSuffix(fileName, ".go")
+}
+
+//rotatingFile is a log file that is moved to <name>.1 when it gets too large. Older files are moved to
+//<name>.2 and so on, and the oldest is deleted.
+type rotatingFile struct {
+	name     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+func openRotatingFile(name string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	r := &rotatingFile{name: name, maxSize: maxSize, maxFiles: maxFiles}
+	return r, r.open()
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
 		}
 	}
-	fileName = strings.ReplaceAll(fileName, ".go", "")
-	return fileName, nil
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	os.Remove(r.name + "." + strconv.Itoa(r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		os.Rename(r.name+"."+strconv.Itoa(i), r.name+"."+strconv.Itoa(i+1))
+	}
+	if r.maxFiles > 0 {
+		os.Rename(r.name, r.name+".1")
+	} else {
+		os.Remove(r.name)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
 }
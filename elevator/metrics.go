@@ -0,0 +1,146 @@
+package elevator
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"./eventManager"
+	"./log"
+	"./metrics"
+)
+
+//Buckets in seconds for the time it takes to serve an order
+var serveBuckets = []float64{1, 2, 5, 10, 15, 20, 30, 45, 60, 90, 120, 300}
+
+var (
+	hallCallWaitTime = metrics.NewHistogram("elevator_hall_call_wait_seconds",
+		"Time from a hall button is pressed on this elevator until an elevator has served the call", serveBuckets)
+	cabRideTime = metrics.NewHistogram("elevator_cab_ride_seconds",
+		"Time from a cab button is pressed until the elevator has arrived at the floor", serveBuckets)
+	ordersAssigned = metrics.NewCounter("elevator_orders_assigned_total",
+		"Hall orders assigned to each elevator, as decided by this elevator", "elevator")
+	assignmentDisagreements = metrics.NewCounter("elevator_assignment_disagreements_total",
+		"Hall orders where the elevators did not agree on the assigned elevator, so all of them serve it")
+	assignmentRoundsRejected = metrics.NewCounter("elevator_assignment_rounds_rejected_total",
+		"Optimal assignment rounds not used, because this elevator got another assignment from the same input")
+	handovers = metrics.NewCounter("elevator_handovers_total",
+		"Hall orders proposed by this elevator to be handed over to an elevator that serves them sooner, by result",
+		"result")
+	assignmentTimeouts = metrics.NewCounter("elevator_assignment_timeouts_total",
+		"Hall orders where not all elevators sent their assigned elevator within MaxDecideTime")
+	packetResends = metrics.NewCounter("elevator_network_packet_resends_total",
+		"Packets sent again because not all elevators acknowledged them within AckTimeout")
+	packetsUnacked = metrics.NewCounter("elevator_network_packets_unacked_total",
+		"Packets not acknowledged by all elevators after AckAttempts resends")
+	connectionChanges = metrics.NewCounter("elevator_connection_changes_total",
+		"Other elevators connecting and disconnecting", "elevator", "state")
+	doorObstructionTime = metrics.NewHistogram("elevator_door_obstruction_seconds",
+		"How long the door of this elevator was obstructed", []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120})
+	partitionChanges = metrics.NewCounter("elevator_partition_changes_total",
+		"Times the elevators this elevator can reach have changed, each starting a new partition epoch")
+	partitionReconciled = metrics.NewCounter("elevator_partition_reconciled_total",
+		"Changes made by this elevator when merging the state of another partition, by kind", "kind")
+	leaderChanges = metrics.NewCounter("elevator_leader_changes_total",
+		"Times this elevator has got a new leader")
+	motorStops = metrics.NewCounter("elevator_motor_stops_total",
+		"Times the motor of this elevator stopped between floors for longer than MaxTravelTime")
+)
+
+//Button presses waiting to be served, by trace ID, for the wait and ride times
+var pressedOrders = struct {
+	sync.Mutex
+	m map[string]pressedOrder
+}{m: make(map[string]pressedOrder)}
+
+type pressedOrder struct {
+	at        time.Time
+	orderType OrderType
+}
+
+//Presses that are never served, e.g. because the order was lost in a crash, are forgotten after this long
+const maxPressedAge = time.Hour
+
+//notePressed is called by the driver when a button is pressed, with the trace ID of the new order
+func notePressed(traceID string, orderType OrderType) {
+	pressedOrders.Lock()
+	defer pressedOrders.Unlock()
+	pressedOrders.m[traceID] = pressedOrder{time.Now(), orderType}
+}
+
+//MetricsModule updates the metrics that can be found from the events, and serves all metrics on /metrics of the
+//control interface
+func MetricsModule() {
+	controlMux.Handle("/metrics", metrics.Handler())
+
+	orderCompleteSub := eventManager.Subscribe[OrderCompleteEvent]()
+	assignedSub := eventManager.Subscribe[AssignedEvent]()
+	assignedOKSub := eventManager.Subscribe[AssignedOKEvent]()
+	connectionSub := eventManager.Subscribe[ConnectionEvent]()
+	obstructedSub := eventManager.Subscribe[ObstructedEvent]()
+
+	var obstructedAt time.Time
+	sweep := time.NewTicker(time.Minute)
+	log.PrintInf("Started")
+	for {
+		select {
+		case evt := <-orderCompleteSub:
+			observeCompleted(evt)
+		case evt := <-assignedSub:
+			//Orders missed during a rejoin are assigned again without an OrderID, they are not counted twice.
+			//Orders assigned by an optimal round have no OrderID either, they are counted by the assigner.
+			//Orders handed over to another elevator are not counted again.
+			if evt.OrderID != -1 {
+				ordersAssigned.Inc(strconv.Itoa(evt.ElevatorID))
+			}
+		case evt := <-assignedOKSub:
+			if !evt.SameAssigned {
+				assignmentDisagreements.Inc()
+			}
+		case evt := <-connectionSub:
+			state := "disconnect"
+			if evt.Connect {
+				state = "connect"
+			}
+			connectionChanges.Inc(strconv.Itoa(evt.ElevatorID), state)
+		case evt := <-obstructedSub:
+			if evt.Obstructed && obstructedAt.IsZero() {
+				obstructedAt = time.Now()
+			} else if !evt.Obstructed && !obstructedAt.IsZero() {
+				doorObstructionTime.Observe(time.Since(obstructedAt).Seconds())
+				obstructedAt = time.Time{}
+			}
+		case <-sweep.C:
+			forgetOldPresses()
+		}
+	}
+}
+
+//observeCompleted adds the wait or ride time of the completed orders that were pressed on this elevator. Cab
+//orders are only completed by this elevator, hall calls by any elevator.
+func observeCompleted(evt OrderCompleteEvent) {
+	pressedOrders.Lock()
+	defer pressedOrders.Unlock()
+	for _, traceID := range evt.TraceIDs {
+		pressed, exist := pressedOrders.m[traceID]
+		if !exist {
+			continue
+		}
+		delete(pressedOrders.m, traceID)
+		if pressed.orderType == orderCab {
+			cabRideTime.Observe(time.Since(pressed.at).Seconds())
+		} else {
+			hallCallWaitTime.Observe(time.Since(pressed.at).Seconds())
+		}
+	}
+}
+
+func forgetOldPresses() {
+	pressedOrders.Lock()
+	defer pressedOrders.Unlock()
+	for traceID, pressed := range pressedOrders.m {
+		if time.Since(pressed.at) > maxPressedAge {
+			delete(pressedOrders.m, traceID)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//metric is a counter or histogram that can write itself in the Prometheus text format
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+var registry = struct {
+	sync.Mutex
+	metrics []metric
+}{}
+
+func register(m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, other := range registry.metrics {
+		if other.name() == m.name() {
+			panic("metrics: " + m.name() + " is registered twice")
+		}
+	}
+	registry.metrics = append(registry.metrics, m)
+}
+
+//Counter is a value that only goes up, with one value for each combination of label values
+type Counter struct {
+	metricName string
+	help       string
+	labels     []string
+	mtx        sync.Mutex
+	values     map[string]float64
+}
+
+//NewCounter registers a counter. The label values are given, in the same order, when the counter is increased.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metricName: name, help: help, labels: labels, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+//Inc adds one to the counter
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+//Add adds v, which must not be negative, to the counter
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := labelKey(c.labels, labelValues)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.values[key] += v
+}
+
+func (c *Counter) name() string {
+	return c.metricName
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	writeHeader(w, c.metricName, c.help, "counter")
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		// a counter without labels always has a value
+		fmt.Fprintf(w, "%s 0\n", c.metricName)
+	}
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, formatLabels(c.labels, key, ""), formatFloat(c.values[key]))
+	}
+}
+
+//Histogram counts observed values in buckets, with one set of buckets for each combination of label values
+type Histogram struct {
+	metricName string
+	help       string
+	buckets    []float64
+	labels     []string
+	mtx        sync.Mutex
+	values     map[string]*histogramValue
+}
+
+type histogramValue struct {
+	// counts[i] is the number of values at or below buckets[i], the last is the +Inf bucket
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+//NewHistogram registers a histogram with the given upper bounds of the buckets, in increasing order
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: the buckets of " + name + " are not sorted")
+	}
+	h := &Histogram{metricName: name, help: help, buckets: buckets, labels: labels, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+//Observe adds a value to the histogram
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := labelKey(h.labels, labelValues)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	value, exist := h.values[key]
+	if !exist {
+		value = &histogramValue{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[key] = value
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			value.counts[i]++
+		}
+	}
+	value.counts[len(h.buckets)]++
+	value.sum += v
+	value.count++
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	writeHeader(w, h.metricName, h.help, "histogram")
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := h.values[key]
+		for i, count := range value.counts {
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatFloat(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(h.labels, key, le), count)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, key, ""), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, key, ""), value.count)
+	}
+}
+
+//WriteText writes all metrics in the Prometheus text format
+func WriteText(w io.Writer) error {
+	registry.Lock()
+	metrics := append([]metric(nil), registry.metrics...)
+	registry.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+//Handler serves the metrics to Prometheus
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+// the label values are kept as one string, separated by a byte that is not in any label value
+const labelSeparator = "\xff"
+
+func labelKey(labels []string, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for the labels %v", len(values), labels))
+	}
+	return strings.Join(values, labelSeparator)
+}
+
+//formatLabels writes the labels of a value, with le added for histogram buckets if not empty
+func formatLabels(labels []string, key string, le string) string {
+	var pairs []string
+	if len(labels) > 0 {
+		for i, value := range strings.Split(key, labelSeparator) {
+			pairs = append(pairs, labels[i]+"=\""+escape(value)+"\"")
+		}
+	}
+	if le != "" {
+		pairs = append(pairs, "le=\""+le+"\"")
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
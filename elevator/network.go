@@ -15,8 +15,11 @@ type typeTaggedJSON struct {
 }
 
 type dataPacket struct {
-	PacketID int
-	D        []byte
+	PacketID     int
+	Floors       int
+	MaxElevators int
+	Assignment   uint32
+	D            []byte
 }
 
 // Network module function.
@@ -25,6 +28,8 @@ func NetworkModule() {
 	log.PrintInf("Started")
 
 	connectPub := make(chan ConnectionEvent)
+	partitionPub := make(chan PartitionEvent)
+	epochPub := make(chan EpochEvent)
 
 	connectSub := make(chan ConnectionEvent)
 	newOrderSub := make(chan NewOrderEvent)
@@ -32,16 +37,36 @@ func NetworkModule() {
 	availabilitySub := make(chan AvailabilityEvent)
 	orderCompleteSub := make(chan OrderCompleteEvent)
 	checkAssignedElevSub := make(chan CheckAssignedElevEvent)
+	hallRequestTableSub := make(chan HallRequestTableEvent)
+	rejoinStateSub := make(chan RejoinStateEvent)
+	rejoinDoneSub := make(chan RejoinDoneEvent)
+	cabOrdersBackupSub := make(chan CabOrdersBackupEvent)
+	elevatorStateSub := make(chan ElevatorStateEvent)
+	cancelOrderSub := make(chan CancelOrderEvent)
+	hallAssignmentSub := make(chan HallAssignmentEvent)
+	handoverProposeSub := make(chan HandoverProposeEvent)
+	handoverAnswerSub := make(chan HandoverAnswerEvent)
+	handoverCommitSub := make(chan HandoverCommitEvent)
+	electionSub := make(chan ElectionEvent)
+	electionAnswerSub := make(chan ElectionAnswerEvent)
+	leaderSub := make(chan LeaderEvent)
+	lostOrdersHandledSub := make(chan LostOrdersHandledEvent)
 
-	eventManager.AddPublishers(connectPub)
-	eventManager.AddSubscribers(connectSub, newOrderSub, costResultSub, availabilitySub, orderCompleteSub, checkAssignedElevSub)
+	eventManager.AddPublishersFrom(eventManager.SourceNetwork, connectPub, partitionPub, epochPub)
+	eventManager.AddSubscribers(connectSub, newOrderSub, costResultSub, availabilitySub, orderCompleteSub, checkAssignedElevSub, hallRequestTableSub,
+		rejoinStateSub, rejoinDoneSub, cabOrdersBackupSub, elevatorStateSub,
+		cancelOrderSub, hallAssignmentSub, handoverProposeSub, handoverAnswerSub, handoverCommitSub,
+		electionSub, electionAnswerSub, leaderSub, lostOrdersHandledSub)
 
 	// Start transmitting and receiving as well as connection checking.
 	// Subscriber channels from eventmanager is fed directly to the transmitter.
 	// Received events i also sent directly to the event manager.
-	go Transmitter(filterElevatorID, connectSub, newOrderSub, costResultSub, availabilitySub, orderCompleteSub, checkAssignedElevSub)
+	go Transmitter(filterElevatorID, connectSub, newOrderSub, costResultSub, availabilitySub, orderCompleteSub, checkAssignedElevSub, hallRequestTableSub,
+		rejoinStateSub, rejoinDoneSub, cabOrdersBackupSub, elevatorStateSub,
+		cancelOrderSub, hallAssignmentSub, handoverProposeSub, handoverAnswerSub, handoverCommitSub,
+		electionSub, electionAnswerSub, leaderSub, lostOrdersHandledSub)
 	go Receiver()
-	go ConnectionCheck(connectPub)
+	go ConnectionCheck(connectPub, partitionPub, epochPub)
 
 	for {
 		time.Sleep(time.Second)
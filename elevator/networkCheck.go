@@ -8,22 +8,62 @@ import (
 	"time"
 
 	"./conn"
+	"./log"
 	"./utils"
 )
 
 type connCheckPacket struct {
-	ElevatorID int
+	ElevatorID   int
+	Floors       int
+	MaxElevators int
+	// Epoch of the partition the sender is in
+	Epoch int
+	// Assignment is the hash of the Assignment settings of the sender
+	Assignment uint32
 }
 
 // Connection check function starts both receiving, sending and handling the connection checking.
-func ConnectionCheck(connect chan<- ConnectionEvent) {
+// The elevators that are connected make up the partition of this elevator, which is published in a
+// PartitionEvent every time it changes. A later epoch taken over from a member is published in an EpochEvent.
+func ConnectionCheck(connect chan<- ConnectionEvent, partition chan<- PartitionEvent, epochAdopted chan<- EpochEvent) {
 	connectionStatus := make([]bool, utils.ELEVATOR_MAX_NUM)
-	var timer [utils.ELEVATOR_MAX_NUM]*time.Timer
-	recieve := make(chan int)
+	timer := make([]*time.Timer, utils.ELEVATOR_MAX_NUM)
+	recieve := make(chan connCheckPacket)
+	sendEpoch := make(chan int)
 
 	receivedFlag := make([]bool, utils.ELEVATOR_MAX_NUM)
 	consecutiveLosses := make([]int, utils.ELEVATOR_MAX_NUM)
 
+	// Last epoch sent by each elevator
+	peerEpochs := make([]int, utils.ELEVATOR_MAX_NUM)
+	epoch := 0
+	var members []int
+	publishPartition := func() {
+		newMembers := []int{}
+		for id, connected := range connectionStatus {
+			if connected || id == utils.ELEVATOR_ID {
+				newMembers = append(newMembers, id)
+			}
+		}
+		if members != nil && !reflect.DeepEqual(members, newMembers) {
+			partitionChanges.Inc()
+		}
+		members = newMembers
+		sendEpoch <- epoch
+		partition <- PartitionEvent{utils.ELEVATOR_ID, epoch, members}
+	}
+	// newEpoch starts an epoch later than any the members have been in, so both sides of a healed partition end
+	// up in the same epoch
+	newEpoch := func() {
+		for id, connected := range connectionStatus {
+			if connected && peerEpochs[id] > epoch {
+				epoch = peerEpochs[id]
+			}
+		}
+		epoch++
+		publishPartition()
+	}
+
 	// Create a select case for receiving an awake message
 	selectCases := make([]reflect.SelectCase, utils.ELEVATOR_MAX_NUM+1)
 	selectCases[0] = reflect.SelectCase{
@@ -33,7 +73,7 @@ func ConnectionCheck(connect chan<- ConnectionEvent) {
 
 	// Create a select case for timeout channel of timers for each elevator
 	for i := 0; i < utils.ELEVATOR_MAX_NUM; i++ {
-		timer[i] = time.NewTimer(utils.CONNECTION_CHECK_INTERVAL * time.Millisecond)
+		timer[i] = time.NewTimer(utils.Config.Network.CheckInterval.Duration)
 		timer[i].Stop()
 		selectCases[1+i] = reflect.SelectCase{
 			Dir:  reflect.SelectRecv,
@@ -42,7 +82,8 @@ func ConnectionCheck(connect chan<- ConnectionEvent) {
 	}
 
 	//Start connection Check sending
-	go connectionCheckSend()
+	go connectionCheckSend(sendEpoch)
+	publishPartition()
 
 	//Start Receiving Connection checks
 	go connectionCheckRecieve(recieve)
@@ -52,35 +93,44 @@ func ConnectionCheck(connect chan<- ConnectionEvent) {
 		switch chosen {
 		case 0:
 			// Awake message received for an elevator
-			ElevatorID := int(value.Int())
+			packet := value.Interface().(connCheckPacket)
+			ElevatorID := packet.ElevatorID
 
 			if ElevatorID != utils.ELEVATOR_ID {
+				peerEpochs[ElevatorID] = packet.Epoch
 				// Set received flag to true, and clear consecutive losses
 				receivedFlag[ElevatorID] = true
 				consecutiveLosses[ElevatorID] = 0
 				if !connectionStatus[ElevatorID] {
 					// If disconnected set to connected
 					timer[ElevatorID].Stop()
-					timer[ElevatorID].Reset(utils.CONNECTION_CHECK_INTERVAL * time.Millisecond)
+					timer[ElevatorID].Reset(utils.Config.Network.CheckInterval.Duration)
 					d := ConnectionEvent{ElevatorID, true}
 					connectionStatus[ElevatorID] = true
 					connect <- d
-
+					newEpoch()
+				} else if packet.Epoch > epoch {
+					// Another member started a later epoch for the same partition. The members have not
+					// changed, so it is published without a new PartitionEvent.
+					epoch = packet.Epoch
+					sendEpoch <- epoch
+					epochAdopted <- EpochEvent{utils.ELEVATOR_ID, epoch}
 				}
 			}
 		default:
 			ElevatorID := chosen - 1
-			timer[ElevatorID].Reset(utils.CONNECTION_CHECK_INTERVAL * time.Millisecond)
+			timer[ElevatorID].Reset(utils.Config.Network.CheckInterval.Duration)
 			if !receivedFlag[ElevatorID] {
 				// If the recived flag is not set, add a to a consecutive loss.
 				// If consecutive losses reaches teshold set to disconnect.
 				consecutiveLosses[ElevatorID]++
 
-				if (consecutiveLosses[ElevatorID]) == utils.CONNECTION_CHECK_TRESHOLD {
+				if (consecutiveLosses[ElevatorID]) == utils.Config.Network.CheckTreshold {
 					d := ConnectionEvent{ElevatorID, false}
 					connectionStatus[ElevatorID] = false
 					connect <- d
 					timer[ElevatorID].Stop()
+					newEpoch()
 				}
 			}
 			// Set received flag to false
@@ -90,30 +140,51 @@ func ConnectionCheck(connect chan<- ConnectionEvent) {
 	}
 }
 
-func connectionCheckSend() {
+func connectionCheckSend(epoch <-chan int) {
 
-	d := connCheckPacket{ElevatorID: utils.ELEVATOR_ID}
-	jsonstr, err := json.Marshal(d)
-	utils.CheckError(err)
+	d := connCheckPacket{ElevatorID: utils.ELEVATOR_ID, Floors: utils.FLOOR_NUM, MaxElevators: utils.ELEVATOR_MAX_NUM,
+		Assignment: utils.AssignmentHash()}
 
-	conn := conn.DialBroadcastUDP(utils.CONNECTION_CHECK_PORT)
-	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", utils.CONNECTION_CHECK_PORT))
+	conn := conn.DialBroadcastUDP(utils.Config.Network.CheckPort)
+	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", utils.Config.Network.CheckPort))
+	ticker := time.NewTicker(utils.Config.Network.CheckInterval.Duration)
 	for {
-
+		jsonstr, err := json.Marshal(d)
+		utils.CheckError(err)
 		conn.WriteTo(jsonstr, addr)
-		time.Sleep(utils.CONNECTION_CHECK_INTERVAL * time.Millisecond)
+
+		select {
+		case d.Epoch = <-epoch:
+		case <-ticker.C:
+		}
 	}
 }
 
-func connectionCheckRecieve(r chan<- int) {
-	var buf [16]byte
-	conn := conn.DialBroadcastUDP(utils.CONNECTION_CHECK_PORT)
+func connectionCheckRecieve(r chan<- connCheckPacket) {
+	var buf [256]byte
+	conn := conn.DialBroadcastUDP(utils.Config.Network.CheckPort)
+	mismatched := make(map[int]bool)
 	for {
 		n, _, e := conn.ReadFrom(buf[0:])
 		utils.CheckError(e)
 		var packet connCheckPacket
 		json.Unmarshal(buf[0:n], &packet)
-		// fmt.Println("Receive", packet.ElevatorID)
-		r <- packet.ElevatorID
+		// Elevators with another building configuration are never considered connected
+		if !utils.SameBuilding(packet.Floors, packet.MaxElevators) || packet.ElevatorID < 0 || packet.ElevatorID >= utils.ELEVATOR_MAX_NUM {
+			if !mismatched[packet.ElevatorID] {
+				log.PrintErr("Ignoring elevator", packet.ElevatorID, "with", packet.Floors, "floors and", packet.MaxElevators, "elevators")
+				mismatched[packet.ElevatorID] = true
+			}
+			continue
+		}
+		// With other Assignment settings every order would be a disagreement served by all elevators
+		if !utils.SameAssignment(packet.Assignment) {
+			if !mismatched[packet.ElevatorID] {
+				log.PrintErr("Ignoring elevator", packet.ElevatorID, "with other Assignment settings")
+				mismatched[packet.ElevatorID] = true
+			}
+			continue
+		}
+		r <- packet
 	}
 }
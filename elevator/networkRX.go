@@ -20,9 +20,9 @@ type recievedPackets struct {
 
 // Receiver function starts receiving data from network and starts routine to handle ack sending.
 func Receiver() {
-	var buf [1024]byte
+	var buf [65536]byte
 	ackChan := make(chan int)
-	conn := conn.DialBroadcastUDP(utils.CONNECTION_DATA_PORT)
+	conn := conn.DialBroadcastUDP(utils.Config.Network.DataPort)
 	var rp recievedPackets
 
 	go transmitAck(ackChan)
@@ -32,11 +32,16 @@ func Receiver() {
 		n, _, e := conn.ReadFrom(buf[0:])
 
 		if e != nil {
-			fmt.Printf("bcast.Receiver(%d, ...):ReadFrom() failed: \"%+v\"\n", utils.CONNECTION_DATA_PORT, e)
+			fmt.Printf("bcast.Receiver(%d, ...):ReadFrom() failed: \"%+v\"\n", utils.Config.Network.DataPort, e)
 		}
 		var packet dataPacket
 		json.Unmarshal(buf[0:n], &packet)
 
+		if !utils.SameBuilding(packet.Floors, packet.MaxElevators) || !utils.SameAssignment(packet.Assignment) {
+			// Packets from elevators with another building configuration or Assignment settings are dropped, and not acked
+			continue
+		}
+
 		if (packet.PacketID >> 8) != utils.ELEVATOR_ID {
 			ackChan <- packet.PacketID
 			if rp.handle(packet.PacketID) {
@@ -51,8 +56,8 @@ func Receiver() {
 
 // Thos loop creates and transmits ack packets for packet IDs received through channel
 func transmitAck(ch <-chan int) {
-	conn := conn.DialBroadcastUDP(utils.CONNECTION_ACK_PORT)
-	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", utils.CONNECTION_ACK_PORT))
+	conn := conn.DialBroadcastUDP(utils.Config.Network.AckPort)
+	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", utils.Config.Network.AckPort))
 	for {
 		packetID := <-ch
 		d := ackPacket{ElevatorID: utils.ELEVATOR_ID, PacketID: packetID}
@@ -85,7 +90,7 @@ func (p *recievedPackets) handle(packetID int) bool {
 	}
 	// add to register
 	p.Packets[p.currentRegister] = append(p.Packets[p.currentRegister], packetID)
-	if len(p.Packets[p.currentRegister]) > utils.RX_PACKET_REGISTER_LENGTH {
+	if len(p.Packets[p.currentRegister]) > utils.Config.Network.RxPacketRegisterLength {
 
 		p.Packets[l] = nil
 		p.currentRegister++
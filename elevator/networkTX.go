@@ -86,7 +86,7 @@ func Transmitter(filter filterFunction, connectionFail chan ConnectionEvent, cha
 				p, _ := json.Marshal(payload)
 				packetID := (utils.ELEVATOR_ID << 8) + (id & 255)
 				id++
-				packet := dataPacket{packetID, p}
+				packet := dataPacket{packetID, utils.FLOOR_NUM, utils.ELEVATOR_MAX_NUM, utils.AssignmentHash(), p}
 				ttj, err := json.Marshal(packet)
 				utils.CheckError(err)
 				log.PrintDbg("expecting ack from", len(availableElevators), "Elevators")
@@ -98,8 +98,8 @@ func Transmitter(filter filterFunction, connectionFail chan ConnectionEvent, cha
 
 // TX writes data sent through channel to the udp port
 func TX(ch <-chan []byte) {
-	conn := conn.DialBroadcastUDP(utils.CONNECTION_DATA_PORT)
-	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", utils.CONNECTION_DATA_PORT))
+	conn := conn.DialBroadcastUDP(utils.Config.Network.DataPort)
+	addr, _ := net.ResolveUDPAddr("udp4", fmt.Sprintf("255.255.255.255:%d", utils.Config.Network.DataPort))
 	for {
 		packet := <-ch
 		conn.WriteTo(packet, addr)
@@ -111,7 +111,7 @@ func TX(ch <-chan []byte) {
 func RXack(AckCh chan<- ackPacket) {
 	var buf [64]byte
 
-	conn := conn.DialBroadcastUDP(utils.CONNECTION_ACK_PORT)
+	conn := conn.DialBroadcastUDP(utils.Config.Network.AckPort)
 	for {
 		var packet ackPacket
 		n, _, e := conn.ReadFrom(buf[0:])
@@ -159,7 +159,7 @@ func handleSend(packetID int, packet []byte, numElevators int, sendCh chan<- []b
 	reciever := AckRoutine{packetID, ackCh}
 	addAckCh <- reciever
 	sendCh <- packet
-	timeout := time.NewTimer(utils.ACK_TIMEOUT * time.Millisecond)
+	timeout := time.NewTimer(utils.Config.Network.AckTimeout.Duration)
 
 	if numElevators == 0 {
 		return
@@ -168,12 +168,14 @@ func handleSend(packetID int, packet []byte, numElevators int, sendCh chan<- []b
 	for {
 		select {
 		case <-timeout.C:
-			if attempts < utils.ACK_ATTEMPTS {
+			if attempts < utils.Config.Network.AckAttempts {
 				attempts++
-				timeout.Reset(utils.ACK_TIMEOUT * time.Millisecond)
+				timeout.Reset(utils.Config.Network.AckTimeout.Duration)
 				sendCh <- packet
+				packetResends.Inc()
 				log.PrintDbg("Packet not acknowledged, resending", packetID)
 			} else {
+				packetsUnacked.Inc()
 				log.PrintErr("Packet not acknowledged", packetID)
 			}
 		case ElevatorID := <-ackCh:
@@ -0,0 +1,124 @@
+package elevator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"./eventManager"
+	"./log"
+	"./utils"
+)
+
+//OperatorModule lets building management place and cancel orders and take the elevator out of service through the
+//control interface. The commands are published as the same events as the buttons, so they are assigned and
+//replicated like a button press.
+func OperatorModule() {
+	newOrderPub := make(chan NewOrderEvent)
+	newCabOrderPub := make(chan NewCabOrderEvent)
+	cancelOrderPub := make(chan CancelOrderEvent)
+	serviceModePub := make(chan ServiceModeEvent)
+	eventManager.AddPublishersFrom(eventManager.SourceOperator, newOrderPub, newCabOrderPub, cancelOrderPub, serviceModePub)
+
+	controlMux.HandleFunc("/orders/hall", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+			return
+		}
+		floor, err := floorParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		orderType, err := directionParam(r, floor)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			log.PrintInf("Operator cancelled hall call on floor", floor, orderTypeName(orderType))
+			cancelOrderPub <- CancelOrderEvent{utils.ELEVATOR_ID, floor, orderType}
+			writeAccepted(w, nil)
+			return
+		}
+		evt := NewOrderEvent{utils.ELEVATOR_ID, floor, newOrderID(), orderType, newTraceID()}
+		log.PrintInf("Operator placed hall call on floor", floor, orderTypeName(orderType), "trace", evt.TraceID)
+		notePressed(evt.TraceID, orderType)
+		newOrderPub <- evt
+		writeAccepted(w, evt)
+	})
+
+	//A cab order is also how the elevator is sent to a floor, e.g. when it is out of service
+	controlMux.HandleFunc("/orders/cab", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+			return
+		}
+		floor, err := floorParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			log.PrintInf("Operator cancelled cab order on floor", floor)
+			cancelOrderPub <- CancelOrderEvent{utils.ELEVATOR_ID, floor, orderCab}
+			writeAccepted(w, nil)
+			return
+		}
+		evt := NewCabOrderEvent{utils.ELEVATOR_ID, floor, newOrderID(), orderCab, newTraceID()}
+		log.PrintInf("Operator placed cab order on floor", floor, "trace", evt.TraceID)
+		notePressed(evt.TraceID, orderCab)
+		newCabOrderPub <- evt
+		writeAccepted(w, evt)
+	})
+
+	controlMux.HandleFunc("/service", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		inService, err := strconv.ParseBool(r.URL.Query().Get("in"))
+		if err != nil {
+			http.Error(w, "in must be true or false", http.StatusBadRequest)
+			return
+		}
+		log.PrintInf("Operator set in service to", inService)
+		evt := ServiceModeEvent{utils.ELEVATOR_ID, inService}
+		serviceModePub <- evt
+		writeAccepted(w, evt)
+	})
+}
+
+func floorParam(r *http.Request) (int, error) {
+	floor, err := strconv.Atoi(r.URL.Query().Get("floor"))
+	if err != nil || floor < 0 || floor >= utils.FLOOR_NUM {
+		return 0, fmt.Errorf("floor must be between 0 and %d", utils.FLOOR_NUM-1)
+	}
+	return floor, nil
+}
+
+//directionParam returns the hall order type of the direction parameter, there is no up call on the top floor and
+//no down call on the bottom floor
+func directionParam(r *http.Request, floor int) (OrderType, error) {
+	switch r.URL.Query().Get("direction") {
+	case "up":
+		if floor == utils.FLOOR_NUM-1 {
+			return orderHallUp, fmt.Errorf("there is no up call on the top floor")
+		}
+		return orderHallUp, nil
+	case "down":
+		if floor == 0 {
+			return orderHallDown, fmt.Errorf("there is no down call on the bottom floor")
+		}
+		return orderHallDown, nil
+	}
+	return orderHallUp, fmt.Errorf("direction must be up or down")
+}
+
+//writeAccepted answers a command, which is carried out after the response has been sent
+func writeAccepted(w http.ResponseWriter, v interface{}) {
+	if v == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, v)
+}
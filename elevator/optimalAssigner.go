@@ -0,0 +1,325 @@
+package elevator
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+
+	"./utils"
+)
+
+//Assignments tried when searching for the optimal one. Larger problems are assigned one call at a time to the
+//elevator that adds the least waiting, and then improved by moving single calls while that helps.
+const maxOptimalCombinations = 1 << 12
+
+type hallCall struct {
+	floor     int
+	orderType OrderType
+}
+
+//optimalAssigner holds what the optimal assignment mode needs to know between the rounds. It is only used from
+//the assigner module.
+type optimalAssigner struct {
+	// the outstanding hall calls, merged from the tables of all elevators like in the active orders module
+	table *hallRequestTable
+	// the elevator each outstanding hall call was last given to, -1 if none
+	holders [][utils.ORDER_TYPE_NUM - 1]int
+	traces  [][utils.ORDER_TYPE_NUM - 1]string
+	round   int
+}
+
+func newOptimalAssigner() *optimalAssigner {
+	a := &optimalAssigner{
+		table:   newHallRequestTable(),
+		holders: make([][utils.ORDER_TYPE_NUM - 1]int, utils.FLOOR_NUM),
+		traces:  make([][utils.ORDER_TYPE_NUM - 1]string, utils.FLOOR_NUM),
+	}
+	for floor := range a.holders {
+		for orderType := range a.holders[floor] {
+			a.holders[floor][orderType] = -1
+		}
+	}
+	return a
+}
+
+func (a *optimalAssigner) observeOrder(evt NewOrderEvent) {
+	if evt.OrderType != orderCab && evt.TraceID != "" {
+		a.traces[evt.Floor][evt.OrderType] = evt.TraceID
+	}
+}
+
+//observeTable merges in a hall request table. Returns true if a hall call became outstanding, so the calls
+//should be assigned again at once.
+func (a *optimalAssigner) observeTable(evt HallRequestTableEvent) bool {
+	if !a.table.merge(evt.ElevatorID, evt.Counters) {
+		return false
+	}
+	newCall := false
+	for floor := range a.holders {
+		for orderType := range a.holders[floor] {
+			if !a.table.outstanding(floor, OrderType(orderType)) {
+				a.holders[floor][orderType] = -1
+				a.traces[floor][orderType] = ""
+			} else if a.holders[floor][orderType] == -1 {
+				newCall = true
+			}
+		}
+	}
+	return newCall
+}
+
+//forget is used when an elevator becomes unavailable or disconnects. It has dropped its hall orders, so they are
+//given out again in the next round, and the orders are revoked on the other elevators.
+func (a *optimalAssigner) forget(elevatorID int) []OrderRevokedEvent {
+	var revoked []OrderRevokedEvent
+	for floor := range a.holders {
+		for orderType, holder := range a.holders[floor] {
+			if holder == elevatorID {
+				a.holders[floor][orderType] = -1
+				revoked = append(revoked, OrderRevokedEvent{elevatorID, floor, OrderType(orderType)})
+			}
+		}
+	}
+	return revoked
+}
+
+//newRound assigns the outstanding hall calls to the available elevators, from the last state of each
+func (a *optimalAssigner) newRound(active []int, states map[int]ElevatorStateEvent) HallAssignmentEvent {
+	input := HallAssignmentInput{make([][utils.ORDER_TYPE_NUM - 1]bool, utils.FLOOR_NUM), []ElevatorStateEvent{}}
+	for floor := range input.HallCalls {
+		for orderType := range input.HallCalls[floor] {
+			input.HallCalls[floor][orderType] = a.table.outstanding(floor, OrderType(orderType))
+		}
+	}
+	for _, id := range active {
+		state, known := states[id]
+		if !known || !state.Available || checkAssignmentState(state) != nil {
+			continue
+		}
+		// the hall orders of the elevator are given out again, only its cab orders are kept
+		cabOrders := make([][utils.ORDER_TYPE_NUM]int, len(state.ActiveOrders))
+		for floor := range state.ActiveOrders {
+			cabOrders[floor][orderCab] = state.ActiveOrders[floor][orderCab]
+		}
+		state.ActiveOrders = cabOrders
+		input.States = append(input.States, state)
+	}
+	a.round++
+	return HallAssignmentEvent{utils.ELEVATOR_ID, a.round, hallAssignmentHash(input), input, optimalHallAssignment(input)}
+}
+
+//apply checks a round from the coordinator by computing the assignment again from its input. If it is the same,
+//the hall calls that are given to another elevator than before are revoked from the old and assigned to the new.
+//Calls that are no longer outstanding here are left out, and a round that arrives late is corrected by the next.
+func (a *optimalAssigner) apply(evt HallAssignmentEvent) ([]OrderRevokedEvent, []AssignedEvent, error) {
+	if err := checkAssignmentInput(evt.Input); err != nil {
+		return nil, nil, err
+	}
+	if hash := hallAssignmentHash(evt.Input); hash != evt.InputHash {
+		return nil, nil, fmt.Errorf("the input hash is %x, but was sent as %x", hash, evt.InputHash)
+	}
+	if assignment := optimalHallAssignment(evt.Input); !reflect.DeepEqual(assignment, evt.Assignment) {
+		return nil, nil, fmt.Errorf("the same input gives %v here, but %v was sent", assignment, evt.Assignment)
+	}
+
+	var revoked []OrderRevokedEvent
+	var assigned []AssignedEvent
+	singleMode := len(evt.Input.States) == 1
+	for _, state := range evt.Input.States {
+		calls := evt.Assignment[state.ElevatorID]
+		for floor := range calls {
+			for orderType, given := range calls[floor] {
+				holder := a.holders[floor][orderType]
+				if !given || holder == state.ElevatorID || !a.table.outstanding(floor, OrderType(orderType)) {
+					continue
+				}
+				if holder != -1 {
+					revoked = append(revoked, OrderRevokedEvent{holder, floor, OrderType(orderType)})
+				}
+				a.holders[floor][orderType] = state.ElevatorID
+				assigned = append(assigned, AssignedEvent{state.ElevatorID, -1, floor, OrderType(orderType), singleMode, a.traces[floor][orderType]})
+			}
+		}
+	}
+	return revoked, assigned, nil
+}
+
+//checkAssignmentInput makes sure an input received from the network can be simulated
+func checkAssignmentInput(input HallAssignmentInput) error {
+	if len(input.HallCalls) != utils.FLOOR_NUM {
+		return fmt.Errorf("the input has %d floors", len(input.HallCalls))
+	}
+	// the simulation never serves these, as the buttons do not exist
+	if input.HallCalls[utils.FLOOR_NUM-1][orderHallUp] || input.HallCalls[0][orderHallDown] {
+		return fmt.Errorf("the input has an up call on the top floor or a down call on the bottom floor")
+	}
+	for i, state := range input.States {
+		if err := checkAssignmentState(state); err != nil {
+			return err
+		}
+		if i > 0 && state.ElevatorID <= input.States[i-1].ElevatorID {
+			return fmt.Errorf("the elevators of the input are not sorted by ID")
+		}
+	}
+	return nil
+}
+
+func checkAssignmentState(state ElevatorStateEvent) error {
+	if state.ElevatorID < 0 || state.ElevatorID >= utils.ELEVATOR_MAX_NUM {
+		return fmt.Errorf("the input has elevator %d", state.ElevatorID)
+	}
+	if len(state.ActiveOrders) != utils.FLOOR_NUM {
+		return fmt.Errorf("elevator %d has orders for %d floors", state.ElevatorID, len(state.ActiveOrders))
+	}
+	// a moving elevator is simulated from the next floor
+	next := state.Floor
+	if state.Behaviour == behaviourMoving {
+		next += int(state.Movement)
+	}
+	if state.Floor < 0 || state.Floor >= utils.FLOOR_NUM || next < 0 || next >= utils.FLOOR_NUM {
+		return fmt.Errorf("elevator %d is at floor %d moving %d", state.ElevatorID, state.Floor, state.Movement)
+	}
+	return nil
+}
+
+//hallAssignmentHash is the FNV-1a hash of the input encoded as JSON, which has the same field order every time
+func hallAssignmentHash(input HallAssignmentInput) uint64 {
+	data, err := json.Marshal(input)
+	utils.CheckError(err)
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+//optimalHallAssignment gives every hall call of the input to one of its elevators, so the sum of the time until
+//each call is served, found with the TimeToServeOrder simulation, is as low as possible. It only depends on the
+//input, so every elevator gets the same assignment from the same input.
+func optimalHallAssignment(input HallAssignmentInput) map[int][][utils.ORDER_TYPE_NUM - 1]bool {
+	assignment := make(map[int][][utils.ORDER_TYPE_NUM - 1]bool)
+	for _, state := range input.States {
+		assignment[state.ElevatorID] = make([][utils.ORDER_TYPE_NUM - 1]bool, len(input.HallCalls))
+	}
+	var calls []hallCall
+	for floor := range input.HallCalls {
+		for orderType, outstanding := range input.HallCalls[floor] {
+			if outstanding {
+				calls = append(calls, hallCall{floor, OrderType(orderType)})
+			}
+		}
+	}
+	if len(calls) == 0 || len(input.States) == 0 {
+		return assignment
+	}
+
+	// chosen[i] is the index in input.States of the elevator serving calls[i]
+	var chosen []int
+	if combinations(len(input.States), len(calls)) <= maxOptimalCombinations {
+		chosen = searchAssignment(input.States, calls)
+	} else {
+		chosen = improveAssignment(input.States, calls)
+	}
+	for i, call := range calls {
+		assignment[input.States[chosen[i]].ElevatorID][call.floor][call.orderType] = true
+	}
+	return assignment
+}
+
+//combinations returns the number of ways to give the calls to the elevators, or a number above
+//maxOptimalCombinations if there are more
+func combinations(elevators, calls int) int {
+	n := 1
+	for i := 0; i < calls && n <= maxOptimalCombinations; i++ {
+		n *= elevators
+	}
+	return n
+}
+
+//searchAssignment tries every assignment, the first one found with the lowest waiting time is returned
+func searchAssignment(states []ElevatorStateEvent, calls []hallCall) []int {
+	chosen := make([]int, len(calls))
+	best := append([]int(nil), chosen...)
+	bestWait := assignmentWait(states, calls, chosen)
+	for {
+		// count up in base len(states)
+		i := 0
+		for i < len(chosen) && chosen[i] == len(states)-1 {
+			chosen[i] = 0
+			i++
+		}
+		if i == len(chosen) {
+			return best
+		}
+		chosen[i]++
+		if wait := assignmentWait(states, calls, chosen); wait < bestWait {
+			bestWait = wait
+			copy(best, chosen)
+		}
+	}
+}
+
+//improveAssignment gives the calls one at a time to the elevator that adds the least waiting time, and then
+//moves single calls to another elevator while that lowers the waiting time
+func improveAssignment(states []ElevatorStateEvent, calls []hallCall) []int {
+	chosen := make([]int, 0, len(calls))
+	for i := range calls {
+		chosen = append(chosen, 0)
+		bestWait := assignmentWait(states, calls[:i+1], chosen)
+		best := 0
+		for e := 1; e < len(states); e++ {
+			chosen[i] = e
+			if wait := assignmentWait(states, calls[:i+1], chosen); wait < bestWait {
+				bestWait = wait
+				best = e
+			}
+		}
+		chosen[i] = best
+	}
+
+	bestWait := assignmentWait(states, calls, chosen)
+	for improved := true; improved; {
+		improved = false
+		for i := range chosen {
+			current := chosen[i]
+			for e := range states {
+				if e == current {
+					continue
+				}
+				chosen[i] = e
+				if wait := assignmentWait(states, calls, chosen); wait < bestWait {
+					bestWait = wait
+					current = e
+					improved = true
+				}
+			}
+			chosen[i] = current
+		}
+	}
+	return chosen
+}
+
+//assignmentWait returns the sum of the time until each call is served, when each elevator serves its cab orders
+//and the calls given to it
+func assignmentWait(states []ElevatorStateEvent, calls []hallCall, chosen []int) int {
+	wait := 0
+	for e, s := range states {
+		state := s.elevatorState()
+		for i, call := range calls {
+			if chosen[i] == e {
+				state.ActiveOrders[call.floor][call.orderType] = 1
+			}
+		}
+		for i, call := range calls {
+			if chosen[i] == e {
+				wait += TimeToServeOrder(state, call.orderType, call.floor)
+			}
+		}
+	}
+	return wait
+}
+
+//elevatorState returns the state sent by an elevator as the state used by the simulation, with its own copy of
+//the active orders
+func (evt ElevatorStateEvent) elevatorState() ElevatorState {
+	return ElevatorState{evt.ElevatorID, evt.Floor, evt.Behaviour, evt.Movement, evt.Available, evt.ActiveOrders}.copyState()
+}
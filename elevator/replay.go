@@ -0,0 +1,45 @@
+package elevator
+
+import (
+	"time"
+
+	"./eventManager"
+	"./log"
+	"./utils"
+)
+
+//RecordingMeta is written to the header of recordings, and used by the replay command to set up the same elevator
+func RecordingMeta() map[string]int {
+	return map[string]int{
+		"ElevatorID":   utils.ELEVATOR_ID,
+		"Floors":       utils.FLOOR_NUM,
+		"MaxElevators": utils.ELEVATOR_MAX_NUM,
+	}
+}
+
+//StartReplayModules starts the modules that make events from the inputs of the elevator, in the same way as main.
+//The driver, network and operator modules are not started, their events are published from the recording by Replay.
+func StartReplayModules() {
+	go ControllerModule()
+	go AssignerModule()
+	go ActiveOrdersModule()
+	go ElectionModule()
+	//main waits this long for the network and driver modules before starting the elevator
+	time.Sleep(2 * time.Second)
+	StartElevator()
+}
+
+//Replay publishes the driver, network and operator events of a recording at the same time after start as they were
+//recorded after the start of the recording. With a speed above 1 the events come faster, but the timers of
+//the modules do not, so the replay is only exact at speed 1.
+func Replay(header eventManager.RecordingHeader, records []eventManager.Record, start time.Time, speed float64) {
+	for _, r := range records {
+		if r.Source == eventManager.SourceLocal {
+			continue
+		}
+		at := start.Add(time.Duration(float64(r.Time.Sub(header.Start)) / speed))
+		time.Sleep(time.Until(at))
+		log.PrintDbg("Replaying", r.Type, "from", r.Source)
+		eventManager.PublishJSONFrom(r.Source, r.Event, r.Type)
+	}
+}
@@ -0,0 +1,133 @@
+package simulator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunScript executes simulator commands read line by line from r. Empty lines and lines
+// starting with # are ignored. Supported commands:
+//
+//	wait <duration>              sleep, e.g. "wait 1500ms"
+//	press <up|down|cab> <floor>  press a button
+//	obstruction <on|off>         set the obstruction switch
+//	stop <on|off>                set the stop button
+//	waitfloor <floor> [timeout]  wait until the cab is at a floor, default timeout 30s
+//	print                        write the simulator state to out
+func (s *Simulator) RunScript(r io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := s.execute(strings.Fields(text), out); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *Simulator) execute(args []string, out io.Writer) error {
+	switch args[0] {
+	case "wait":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: wait <duration>")
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		time.Sleep(d)
+	case "press":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: press <up|down|cab> <floor>")
+		}
+		button, err := parseButton(args[1])
+		if err != nil {
+			return err
+		}
+		floor, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+		return s.PressButton(button, floor)
+	case "obstruction", "stop":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s <on|off>", args[0])
+		}
+		value, err := parseSwitch(args[1])
+		if err != nil {
+			return err
+		}
+		if args[0] == "stop" {
+			s.SetStop(value)
+		} else {
+			s.SetObstruction(value)
+		}
+	case "waitfloor":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("usage: waitfloor <floor> [timeout]")
+		}
+		floor, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		timeout := 30 * time.Second
+		if len(args) == 3 {
+			if timeout, err = time.ParseDuration(args[2]); err != nil {
+				return err
+			}
+		}
+		return s.waitFloor(floor, timeout)
+	case "print":
+		st := s.State()
+		fmt.Fprintf(out, "position %.2f  floor %d  direction %d  door %v  obstruction %v  stop %v\n",
+			st.Position, st.Floor, st.Direction, st.DoorOpen, st.Obstruction, st.Stop)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return nil
+}
+
+func (s *Simulator) waitFloor(floor int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mtx.Lock()
+		f := s.sensorFloor()
+		s.mtx.Unlock()
+		if f == floor {
+			return nil
+		}
+		time.Sleep(tickRate)
+	}
+	return fmt.Errorf("cab did not reach floor %d within %v", floor, timeout)
+}
+
+func parseButton(s string) (int, error) {
+	switch s {
+	case "up":
+		return ButtonHallUp, nil
+	case "down":
+		return ButtonHallDown, nil
+	case "cab":
+		return ButtonCab, nil
+	}
+	return 0, fmt.Errorf("unknown button %q", s)
+}
+
+func parseSwitch(s string) (bool, error) {
+	switch s {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off, got %q", s)
+}
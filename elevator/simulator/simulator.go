@@ -0,0 +1,304 @@
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Button types as used by the driver protocol
+const (
+	ButtonHallUp = iota
+	ButtonHallDown
+	ButtonCab
+	buttonTypeNum
+)
+
+// tickRate is how often the position of the cab is updated
+const tickRate = 5 * time.Millisecond
+
+// Config holds the settings of the simulated elevator. The names follow simulator.con.
+type Config struct {
+	Port                    int
+	NumFloors               int
+	TravelTimeBetweenFloors time.Duration
+	TravelTimePassingFloor  time.Duration
+	BtnDepressedTime        time.Duration
+	StartFloor              int
+	StopMotorOnDisconnect   bool
+}
+
+// DefaultConfig returns the same settings as simulator.con
+func DefaultConfig() Config {
+	return Config{
+		Port:                    15657,
+		NumFloors:               4,
+		TravelTimeBetweenFloors: 2000 * time.Millisecond,
+		TravelTimePassingFloor:  500 * time.Millisecond,
+		BtnDepressedTime:        200 * time.Millisecond,
+		StartFloor:              0,
+		StopMotorOnDisconnect:   true,
+	}
+}
+
+// State is a snapshot of the simulated hardware
+type State struct {
+	Position       float64
+	Floor          int
+	Direction      int
+	FloorIndicator int
+	DoorOpen       bool
+	StopLamp       bool
+	Obstruction    bool
+	Stop           bool
+	ButtonLamps    [][buttonTypeNum]bool
+}
+
+// Simulator is an elevator simulated in memory, served over the driver TCP protocol
+type Simulator struct {
+	cfg Config
+	mtx sync.Mutex
+
+	// position is measured in floors, where 0 is the bottom floor
+	position       float64
+	direction      int
+	floorIndicator int
+	doorOpen       bool
+	stopLamp       bool
+	obstruction    bool
+	stop           bool
+	buttonLamps    [][buttonTypeNum]bool
+	pressedUntil   [][buttonTypeNum]time.Time
+	clients        int
+
+	quit chan struct{}
+}
+
+// New creates a simulator and starts moving the cab according to the motor direction
+func New(cfg Config) (*Simulator, error) {
+	if cfg.NumFloors < 2 {
+		return nil, fmt.Errorf("simulator: numFloors must be at least 2, got %d", cfg.NumFloors)
+	}
+	if cfg.StartFloor < 0 || cfg.StartFloor >= cfg.NumFloors {
+		return nil, fmt.Errorf("simulator: start floor %d outside 0..%d", cfg.StartFloor, cfg.NumFloors-1)
+	}
+	if cfg.TravelTimeBetweenFloors <= 0 || cfg.TravelTimePassingFloor < 0 || cfg.TravelTimePassingFloor >= cfg.TravelTimeBetweenFloors {
+		return nil, fmt.Errorf("simulator: passing time must be shorter than travel time between floors")
+	}
+	s := &Simulator{
+		cfg:            cfg,
+		position:       float64(cfg.StartFloor),
+		floorIndicator: cfg.StartFloor,
+		buttonLamps:    make([][buttonTypeNum]bool, cfg.NumFloors),
+		pressedUntil:   make([][buttonTypeNum]time.Time, cfg.NumFloors),
+		quit:           make(chan struct{}),
+	}
+	go s.move()
+	return s, nil
+}
+
+// ListenAndServe accepts driver connections on the configured port
+func (s *Simulator) ListenAndServe() error {
+	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", s.cfg.Port))
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts driver connections on the listener until it is closed
+func (s *Simulator) Serve(l net.Listener) error {
+	go func() {
+		<-s.quit
+		l.Close()
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.handle(c)
+	}
+}
+
+// Close stops the simulation and the listener
+func (s *Simulator) Close() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+}
+
+// handle answers the 4 byte commands of one driver connection
+func (s *Simulator) handle(c net.Conn) {
+	defer c.Close()
+	s.mtx.Lock()
+	s.clients++
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		s.clients--
+		if s.clients == 0 && s.cfg.StopMotorOnDisconnect {
+			s.direction = 0
+		}
+		s.mtx.Unlock()
+	}()
+
+	var buf [4]byte
+	for {
+		if _, err := io.ReadFull(c, buf[:]); err != nil {
+			return
+		}
+		reply, ok := s.command(buf)
+		if ok {
+			if _, err := c.Write(reply[:]); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// command executes one protocol command. Returns the reply and whether the command expects one.
+func (s *Simulator) command(cmd [4]byte) ([4]byte, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	reply := [4]byte{cmd[0], 0, 0, 0}
+
+	switch cmd[0] {
+	case 1:
+		s.direction = int(int8(cmd[1]))
+	case 2:
+		if s.validButton(int(cmd[1]), int(cmd[2])) {
+			s.buttonLamps[cmd[2]][cmd[1]] = cmd[3] != 0
+		}
+	case 3:
+		if int(cmd[1]) < s.cfg.NumFloors {
+			s.floorIndicator = int(cmd[1])
+		}
+	case 4:
+		s.doorOpen = cmd[1] != 0
+	case 5:
+		s.stopLamp = cmd[1] != 0
+	case 6:
+		if s.validButton(int(cmd[1]), int(cmd[2])) {
+			reply[1] = toByte(time.Now().Before(s.pressedUntil[cmd[2]][cmd[1]]))
+		}
+		return reply, true
+	case 7:
+		if f := s.sensorFloor(); f != -1 {
+			reply[1] = 1
+			reply[2] = byte(f)
+		}
+		return reply, true
+	case 8:
+		reply[1] = toByte(s.stop)
+		return reply, true
+	case 9:
+		reply[1] = toByte(s.obstruction)
+		return reply, true
+	}
+	return reply, false
+}
+
+// move updates the cab position according to the motor direction
+func (s *Simulator) move() {
+	ticker := time.NewTicker(tickRate)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case now := <-ticker.C:
+			s.mtx.Lock()
+			step := float64(now.Sub(last)) / float64(s.cfg.TravelTimeBetweenFloors)
+			s.position += float64(s.direction) * step
+			s.position = math.Max(0, math.Min(float64(s.cfg.NumFloors-1), s.position))
+			s.mtx.Unlock()
+			last = now
+		}
+	}
+}
+
+// sensorFloor returns the floor the cab is at, or -1 when it is outside every floor sensor window
+func (s *Simulator) sensorFloor() int {
+	nearest := math.Round(s.position)
+	window := float64(s.cfg.TravelTimePassingFloor) / float64(s.cfg.TravelTimeBetweenFloors) / 2
+	if math.Abs(s.position-nearest) <= window {
+		return int(nearest)
+	}
+	return -1
+}
+
+func (s *Simulator) validButton(button, floor int) bool {
+	if button < 0 || button >= buttonTypeNum || floor < 0 || floor >= s.cfg.NumFloors {
+		return false
+	}
+	// There is no hall up button on the top floor and no hall down button on the bottom floor
+	if (button == ButtonHallUp && floor == s.cfg.NumFloors-1) || (button == ButtonHallDown && floor == 0) {
+		return false
+	}
+	return true
+}
+
+// PressButton holds a button down for BtnDepressedTime
+func (s *Simulator) PressButton(button, floor int) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.validButton(button, floor) {
+		return fmt.Errorf("simulator: no button %d on floor %d", button, floor)
+	}
+	s.pressedUntil[floor][button] = time.Now().Add(s.cfg.BtnDepressedTime)
+	return nil
+}
+
+// SetObstruction sets the obstruction switch
+func (s *Simulator) SetObstruction(value bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.obstruction = value
+}
+
+// SetStop sets the stop button
+func (s *Simulator) SetStop(value bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.stop = value
+}
+
+// State returns a snapshot of the simulated hardware
+func (s *Simulator) State() State {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	lamps := make([][buttonTypeNum]bool, len(s.buttonLamps))
+	copy(lamps, s.buttonLamps)
+	return State{
+		Position:       s.position,
+		Floor:          s.sensorFloor(),
+		Direction:      s.direction,
+		FloorIndicator: s.floorIndicator,
+		DoorOpen:       s.doorOpen,
+		StopLamp:       s.stopLamp,
+		Obstruction:    s.obstruction,
+		Stop:           s.stop,
+		ButtonLamps:    lamps,
+	}
+}
+
+func toByte(a bool) byte {
+	if a {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package supervisor
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"../log"
+	"../utils"
+)
+
+//Run starts this program again as a child process with the same arguments, except -supervise, and restarts it
+//whenever it exits or stops sending heartbeats. The restarted elevator restores its cab orders from the backup
+//file and the other elevators, and rejoins the network like any connecting elevator. Only returns on error.
+func Run(args []string) error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	heartbeats := make(chan struct{}, 1)
+	go receiveHeartbeats(conn, heartbeats)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	childArgs := append(childArguments(args), "-heartbeat="+strconv.Itoa(port))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	for restarts := 0; ; restarts++ {
+		cmd := exec.Command(exe, childArgs...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		log.PrintInf("Started elevator, pid", cmd.Process.Pid, "restarts", restarts)
+
+		reason, stop := watch(cmd, heartbeats, signals)
+		if stop {
+			log.PrintInf("Supervisor stopped:", reason)
+			return nil
+		}
+		log.PrintErr("Restarting elevator,", reason)
+		time.Sleep(utils.Config.Supervisor.RestartDelay.Duration)
+	}
+}
+
+//watch waits until the child exits, stops sending heartbeats or the supervisor is stopped. The child is killed in
+//the last two cases. Returns why and whether the supervisor should stop.
+func watch(cmd *exec.Cmd, heartbeats chan struct{}, signals chan os.Signal) (string, bool) {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	// heartbeat left over from the previous child
+	select {
+	case <-heartbeats:
+	default:
+	}
+
+	settings := utils.Config.Supervisor
+	timer := time.NewTimer(settings.StartupTimeout.Duration)
+	defer timer.Stop()
+	for {
+		select {
+		case <-heartbeats:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(settings.HeartbeatTimeout.Duration)
+		case err := <-exited:
+			if err == nil {
+				return "elevator exited", false
+			}
+			return fmt.Sprint("elevator exited: ", err), false
+		case <-timer.C:
+			cmd.Process.Kill()
+			<-exited
+			return "no heartbeat from elevator", false
+		case sig := <-signals:
+			cmd.Process.Kill()
+			<-exited
+			return sig.String(), true
+		}
+	}
+}
+
+func receiveHeartbeats(conn *net.UDPConn, heartbeats chan struct{}) {
+	buf := make([]byte, 64)
+	for {
+		if _, _, err := conn.ReadFromUDP(buf); err != nil {
+			log.PrintErr("Could not read heartbeat:", err)
+			return
+		}
+		select {
+		case heartbeats <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//childArguments removes the flags set by the supervisor from args
+func childArguments(args []string) []string {
+	var childArgs []string
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if name == "supervise" || strings.HasPrefix(name, "supervise=") || strings.HasPrefix(name, "heartbeat=") {
+			continue
+		}
+		if name == "heartbeat" {
+			i++
+			continue
+		}
+		childArgs = append(childArgs, args[i])
+	}
+	return childArgs
+}
@@ -0,0 +1,63 @@
+package elevator
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"./utils"
+)
+
+//Trace IDs are unique across elevators and restarts: elevator ID, start time of the process and a counter
+var traceStart = time.Now().Unix()
+var traceCounter uint64
+
+//newTraceID returns an ID that follows an order from the button press until it is completed, across modules and
+//elevators. The trace tool puts the recorded events of all elevators together by this ID.
+func newTraceID() string {
+	return fmt.Sprintf("%d-%x-%d", utils.ELEVATOR_ID, traceStart, atomic.AddUint64(&traceCounter, 1))
+}
+
+//Trace IDs of the orders of this elevator, used by the controller to tell which orders an OrderCompleteEvent completes
+var orderTraces [][utils.ORDER_TYPE_NUM]string
+
+func setOrderTrace(floor int, orderType OrderType, traceID string) {
+	if orderTraces == nil {
+		orderTraces = make([][utils.ORDER_TYPE_NUM]string, utils.FLOOR_NUM)
+	}
+	if traceID != "" {
+		orderTraces[floor][orderType] = traceID
+	}
+}
+
+//takeOrderTraces returns the trace IDs of the orders on a floor, and forgets them as the orders are completed
+func takeOrderTraces(floor int) []string {
+	var traceIDs []string
+	if orderTraces == nil {
+		return traceIDs
+	}
+	for orderType, traceID := range orderTraces[floor] {
+		if traceID != "" {
+			traceIDs = append(traceIDs, traceID)
+			orderTraces[floor][orderType] = ""
+		}
+	}
+	return traceIDs
+}
+
+//clearHallOrderTraces forgets the hall orders on a floor, when they are completed by another elevator
+func clearHallOrderTraces(floor int) {
+	if orderTraces == nil {
+		return
+	}
+	for orderType := 0; orderType < utils.ORDER_TYPE_NUM-1; orderType++ {
+		orderTraces[floor][orderType] = ""
+	}
+}
+
+//forgetOrderTrace forgets the trace of a cancelled order
+func forgetOrderTrace(floor int, orderType OrderType) {
+	if orderTraces != nil {
+		orderTraces[floor][orderType] = ""
+	}
+}
@@ -0,0 +1,363 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"../log"
+)
+
+//Config holds the settings of this elevator, loaded from the config file by Init
+var Config Settings
+
+//Settings is the schema of the config file
+type Settings struct {
+	Building   BuildingSettings
+	Elevator   ElevatorSettings
+	Network    NetworkSettings
+	Supervisor SupervisorSettings
+	Assignment AssignmentSettings
+	Control    ControlSettings
+	Logging    LoggingSettings
+}
+
+//BuildingSettings is the geometry of the building. All elevators in the system must use the same settings.
+type BuildingSettings struct {
+	// Floors is the number of floors
+	Floors int
+	// MaxElevators is max number of elevators in system
+	MaxElevators int
+}
+
+//ElevatorSettings holds the timing of a single elevator
+type ElevatorSettings struct {
+	// DoorOpenTime is how long door is open on floor arrival
+	DoorOpenTime Duration
+	// TravelTime is the time it takes for the elevator to move for one floor to another
+	TravelTime Duration
+	// MaxObstructTime is time before an obstructed elevator is considered unavailable
+	MaxObstructTime Duration
+	// MaxTravelTime is the longest time between floors before the motor is considered stopped
+	MaxTravelTime Duration
+	// MaxDecideTime is the max time for agreeing on an order
+	MaxDecideTime Duration
+}
+
+//NetworkSettings holds the ports and timing of the network module
+type NetworkSettings struct {
+	// DataPort is the UDP port used to send data packets
+	DataPort int
+	// CheckPort is the UDP port used to send awake messages
+	CheckPort int
+	// AckPort is the UDP port used to send Ack messages
+	AckPort int
+	// CheckInterval is the interval between awake messages
+	CheckInterval Duration
+	// CheckTreshold is the number of awake messages that has to be lost for connection to be considered lost.
+	// Dropout latency = Interval * Treshold
+	CheckTreshold int
+	// AckTimeout is how long network module waits for ack. If all acks are not in before timeout, the packet is resent.
+	AckTimeout Duration
+	// AckAttempts is the number of times the network module tries to resend a packet after timout.
+	AckAttempts int
+	// RxPacketRegisterLength is the number of packet IDs stored to prevent duplicates.
+	RxPacketRegisterLength int
+	// HallTableInterval is the interval between sending the replicated hall call table to the other elevators
+	HallTableInterval Duration
+	// StateInterval is the interval between sending the state of the elevator to the others, it is also sent when it changes
+	StateInterval Duration
+	// RejoinTimeout is how long a connecting elevator is left out of order assignment while waiting for it to sync
+	RejoinTimeout Duration
+	// ElectionTimeout is how long an elevator waits for an answer from an elevator with a lower ID in a leader election
+	ElectionTimeout Duration
+	// LeaderInterval is the interval between the leader announcing itself to the other elevators
+	LeaderInterval Duration
+}
+
+//SupervisorSettings holds the timing of the supervisor started with -supervise
+type SupervisorSettings struct {
+	// HeartbeatInterval is the interval between heartbeats sent from the elevator to the supervisor
+	HeartbeatInterval Duration
+	// HeartbeatTimeout is how long the supervisor waits for a heartbeat before the elevator is restarted
+	HeartbeatTimeout Duration
+	// StartupTimeout is how long the supervisor waits for the first heartbeat after starting the elevator
+	StartupTimeout Duration
+	// RestartDelay is the time between the elevator dying and it being started again
+	RestartDelay Duration
+}
+
+//Assignment modes. In the bidding mode each hall call is assigned when it is pressed, by the strategy. In the
+//optimal mode a coordinator assigns all outstanding hall calls together every Assignment.Interval.
+const (
+	ModeBidding = "bidding"
+	ModeOptimal = "optimal"
+)
+
+//Assignment strategies, see AssignmentStrategy in the elevator package
+const (
+	StrategyLowestCost   = "lowest-cost"
+	StrategyRoundRobin   = "round-robin"
+	StrategyNearestCar   = "nearest-car"
+	StrategyLoadBalanced = "load-balanced"
+	StrategyZoned        = "zoned"
+)
+
+//AssignmentSettings decides how hall orders are given to the elevators. All elevators in the system must use the
+//same settings, or they will not agree on the assigned elevator.
+type AssignmentSettings struct {
+	// Mode is bidding or optimal
+	Mode string
+	// Interval is how often the coordinator assigns the hall calls again in the optimal mode
+	Interval Duration
+	// ReassignInterval is how often the coordinator looks for hall orders another elevator would serve sooner in
+	// the bidding mode, 0 turns it off
+	ReassignInterval Duration
+	// ReassignMargin is how much sooner the other elevator must serve the order for it to be handed over
+	ReassignMargin Duration
+	// Strategy is lowest-cost, round-robin, nearest-car, load-balanced or zoned, used in the bidding mode
+	Strategy string
+	// Zones is the lowest and highest floor served by each elevator with the zoned strategy, by elevator ID.
+	// Elevators without a zone serve all floors.
+	Zones map[int][2]int
+}
+
+//ControlSettings holds the settings of the control interface, a HTTP server on localhost
+type ControlSettings struct {
+	// Port is the port of elevator 0, the other elevators use Port + ID, so they can run on the same computer.
+	// 0 turns the control interface off.
+	Port int
+}
+
+//LoggingSettings holds the log level of each module file, where the log is written and which events are logged by
+//the event manager
+type LoggingSettings struct {
+	// Level is the log level of modules not in Modules, DBG, INF, WRN or ERR
+	Level string
+	// Modules is the log level of each module, by file name without .go, e.g. "controller"
+	Modules map[string]string
+	// Format is text, json or logfmt
+	Format string
+	// Stdout turns printing of the log to stdout on or off
+	Stdout bool
+	// File is the log file, or empty to not write a log file. %d is replaced by the elevator ID.
+	File string
+	// MaxFileSizeMB is the size the log file is rotated at
+	MaxFileSizeMB int
+	// MaxFiles is the number of rotated log files kept
+	MaxFiles int
+	// Events turns logging of each event type on or off, by type name, e.g. "AssignedEvent". "Logging" turns
+	// logging of all events on or off.
+	Events map[string]bool
+}
+
+//LogSettings converts the settings to the settings of the log package. Must only be called on validated settings.
+func (l LoggingSettings) LogSettings() log.Settings {
+	settings := log.Settings{
+		Modules:     make(map[string]log.Level),
+		Format:      l.Format,
+		Stdout:      l.Stdout,
+		MaxFileSize: int64(l.MaxFileSizeMB) << 20,
+		MaxFiles:    l.MaxFiles,
+		ElevatorID:  ELEVATOR_ID,
+	}
+	settings.Level, _ = log.ParseLevel(l.Level)
+	for module, level := range l.Modules {
+		settings.Modules[moduleName(module)], _ = log.ParseLevel(level)
+	}
+	if l.File != "" {
+		settings.File = strings.Replace(l.File, "%d", strconv.Itoa(ELEVATOR_ID), -1)
+	}
+	return settings
+}
+
+//moduleName removes the Logging suffix used by the modules in old config files
+func moduleName(key string) string {
+	if key != "Logging" {
+		return strings.TrimSuffix(key, "Logging")
+	}
+	return key
+}
+
+//Duration is a time.Duration written as a string in the config file, e.g. "500ms" or "3s"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"500ms\", got %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+//DefaultSettings returns the settings used for everything not set in the config file
+func DefaultSettings() Settings {
+	return Settings{
+		Building: BuildingSettings{
+			Floors:       4,
+			MaxElevators: 3,
+		},
+		Elevator: ElevatorSettings{
+			DoorOpenTime:    Duration{3 * time.Second},
+			TravelTime:      Duration{3 * time.Second},
+			MaxObstructTime: Duration{7 * time.Second},
+			MaxTravelTime:   Duration{6 * time.Second},
+			MaxDecideTime:   Duration{500 * time.Millisecond},
+		},
+		Network: NetworkSettings{
+			DataPort:               12067,
+			CheckPort:              12068,
+			AckPort:                12069,
+			CheckInterval:          Duration{100 * time.Millisecond},
+			CheckTreshold:          10,
+			AckTimeout:             Duration{15 * time.Millisecond},
+			AckAttempts:            30,
+			RxPacketRegisterLength: 36,
+			HallTableInterval:      Duration{500 * time.Millisecond},
+			StateInterval:          Duration{time.Second},
+			RejoinTimeout:          Duration{3 * time.Second},
+			ElectionTimeout:        Duration{500 * time.Millisecond},
+			LeaderInterval:         Duration{time.Second},
+		},
+		Supervisor: SupervisorSettings{
+			HeartbeatInterval: Duration{200 * time.Millisecond},
+			HeartbeatTimeout:  Duration{2 * time.Second},
+			StartupTimeout:    Duration{10 * time.Second},
+			RestartDelay:      Duration{time.Second},
+		},
+		Assignment: AssignmentSettings{
+			Mode:             ModeBidding,
+			Interval:         Duration{time.Second},
+			ReassignInterval: Duration{2 * time.Second},
+			ReassignMargin:   Duration{3 * time.Second},
+			Strategy:         StrategyLowestCost,
+			Zones:            map[int][2]int{},
+		},
+		Control: ControlSettings{
+			Port: 12080,
+		},
+		Logging: LoggingSettings{
+			Level:         "INF",
+			Modules:       map[string]string{},
+			Format:        log.FormatText,
+			Stdout:        true,
+			MaxFileSizeMB: 10,
+			MaxFiles:      3,
+			Events:        map[string]bool{},
+		},
+	}
+}
+
+//LoadSettings reads the config file on top of the default settings. Unknown keys are an error, to catch typos.
+func LoadSettings(filename string) (Settings, error) {
+	settings := DefaultSettings()
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return settings, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return settings, fmt.Errorf("%s: %v", filename, err)
+	}
+	return settings, nil
+}
+
+//Validate checks that all settings are within range. All problems are reported at once.
+func (s Settings) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	b := s.Building
+	// Floors are sent as one byte in the driver protocol
+	check(b.Floors >= 2 && b.Floors <= 255, "Building.Floors must be between 2 and 255, got %d", b.Floors)
+	check(b.MaxElevators >= 1, "Building.MaxElevators must be at least 1, got %d", b.MaxElevators)
+	check(ELEVATOR_ID >= 0 && ELEVATOR_ID < b.MaxElevators, "elevator ID must be between 0 and %d, got %d", b.MaxElevators-1, ELEVATOR_ID)
+
+	e := s.Elevator
+	check(inRange(e.DoorOpenTime, time.Second, 5*time.Second), "Elevator.DoorOpenTime must be between 1s and 5s, got %v", e.DoorOpenTime)
+	check(e.TravelTime.Duration > 0, "Elevator.TravelTime must be positive, got %v", e.TravelTime)
+	check(e.MaxTravelTime.Duration > e.TravelTime.Duration, "Elevator.MaxTravelTime must be longer than TravelTime, got %v", e.MaxTravelTime)
+	check(e.MaxObstructTime.Duration > e.DoorOpenTime.Duration, "Elevator.MaxObstructTime must be longer than DoorOpenTime, got %v", e.MaxObstructTime)
+	check(inRange(e.MaxDecideTime, 10*time.Millisecond, 10*time.Second), "Elevator.MaxDecideTime must be between 10ms and 10s, got %v", e.MaxDecideTime)
+
+	n := s.Network
+	for name, port := range map[string]int{"DataPort": n.DataPort, "CheckPort": n.CheckPort, "AckPort": n.AckPort} {
+		check(port > 0 && port < 65536, "Network.%s must be between 1 and 65535, got %d", name, port)
+	}
+	check(n.DataPort != n.CheckPort && n.DataPort != n.AckPort && n.CheckPort != n.AckPort, "Network ports must be different")
+	check(inRange(n.CheckInterval, 10*time.Millisecond, 10*time.Second), "Network.CheckInterval must be between 10ms and 10s, got %v", n.CheckInterval)
+	check(n.CheckTreshold >= 1, "Network.CheckTreshold must be at least 1, got %d", n.CheckTreshold)
+	check(inRange(n.AckTimeout, time.Millisecond, 10*time.Second), "Network.AckTimeout must be between 1ms and 10s, got %v", n.AckTimeout)
+	check(n.AckAttempts >= 0, "Network.AckAttempts must not be negative, got %d", n.AckAttempts)
+	check(inRange(n.HallTableInterval, 10*time.Millisecond, 10*time.Second), "Network.HallTableInterval must be between 10ms and 10s, got %v", n.HallTableInterval)
+	check(inRange(n.StateInterval, 10*time.Millisecond, 10*time.Second), "Network.StateInterval must be between 10ms and 10s, got %v", n.StateInterval)
+	check(inRange(n.RejoinTimeout, 100*time.Millisecond, time.Minute), "Network.RejoinTimeout must be between 100ms and 1m, got %v", n.RejoinTimeout)
+	check(inRange(n.ElectionTimeout, 10*time.Millisecond, 10*time.Second), "Network.ElectionTimeout must be between 10ms and 10s, got %v", n.ElectionTimeout)
+	check(inRange(n.LeaderInterval, 10*time.Millisecond, 10*time.Second), "Network.LeaderInterval must be between 10ms and 10s, got %v", n.LeaderInterval)
+	check(n.RxPacketRegisterLength >= 1, "Network.RxPacketRegisterLength must be at least 1, got %d", n.RxPacketRegisterLength)
+
+	sv := s.Supervisor
+	check(inRange(sv.HeartbeatInterval, 10*time.Millisecond, 10*time.Second), "Supervisor.HeartbeatInterval must be between 10ms and 10s, got %v", sv.HeartbeatInterval)
+	check(sv.HeartbeatTimeout.Duration > sv.HeartbeatInterval.Duration, "Supervisor.HeartbeatTimeout must be longer than HeartbeatInterval, got %v", sv.HeartbeatTimeout)
+	check(sv.StartupTimeout.Duration >= sv.HeartbeatTimeout.Duration, "Supervisor.StartupTimeout must not be shorter than HeartbeatTimeout, got %v", sv.StartupTimeout)
+	check(inRange(sv.RestartDelay, 0, time.Minute), "Supervisor.RestartDelay must be between 0s and 1m, got %v", sv.RestartDelay)
+
+	a := s.Assignment
+	check(a.Mode == ModeBidding || a.Mode == ModeOptimal, "Assignment.Mode must be %s or %s, got %q", ModeBidding, ModeOptimal, a.Mode)
+	check(inRange(a.Interval, 100*time.Millisecond, time.Minute), "Assignment.Interval must be between 100ms and 1m, got %v", a.Interval)
+	check(a.ReassignInterval.Duration == 0 || inRange(a.ReassignInterval, 100*time.Millisecond, time.Minute),
+		"Assignment.ReassignInterval must be 0 or between 100ms and 1m, got %v", a.ReassignInterval)
+	check(a.ReassignMargin.Duration >= 0, "Assignment.ReassignMargin must not be negative, got %v", a.ReassignMargin)
+	switch a.Strategy {
+	case StrategyLowestCost, StrategyRoundRobin, StrategyNearestCar, StrategyLoadBalanced, StrategyZoned:
+	default:
+		check(false, "Assignment.Strategy must be %s, %s, %s, %s or %s, got %q", StrategyLowestCost, StrategyRoundRobin,
+			StrategyNearestCar, StrategyLoadBalanced, StrategyZoned, a.Strategy)
+	}
+	for id, zone := range a.Zones {
+		check(id >= 0 && id < b.MaxElevators, "Assignment.Zones has elevator %d, but there are only %d elevators", id, b.MaxElevators)
+		check(zone[0] >= 0 && zone[0] <= zone[1] && zone[1] < b.Floors, "Assignment.Zones.%d must be two floors from low to high between 0 and %d, got %v", id, b.Floors-1, zone)
+	}
+
+	c := s.Control
+	check(c.Port >= 0 && c.Port+b.MaxElevators <= 65536, "Control.Port must be between 0 and %d, got %d", 65536-b.MaxElevators, c.Port)
+
+	l := s.Logging
+	_, err := log.ParseLevel(l.Level)
+	check(err == nil, "Logging.Level must be DBG, INF, WRN or ERR, got %q", l.Level)
+	for module, level := range l.Modules {
+		_, err := log.ParseLevel(level)
+		check(err == nil, "Logging.Modules.%s must be DBG, INF, WRN or ERR, got %q", module, level)
+	}
+	check(l.Format == log.FormatText || l.Format == log.FormatJSON || l.Format == log.FormatLogfmt, "Logging.Format must be text, json or logfmt, got %q", l.Format)
+	check(l.MaxFileSizeMB >= 1, "Logging.MaxFileSizeMB must be at least 1, got %d", l.MaxFileSizeMB)
+	check(l.MaxFiles >= 0 && l.MaxFiles <= 100, "Logging.MaxFiles must be between 0 and 100, got %d", l.MaxFiles)
+	check(l.Stdout || l.File != "", "Logging must have Stdout on or a File")
+
+	return errors.Join(errs...)
+}
+
+func inRange(d Duration, min, max time.Duration) bool {
+	return d.Duration >= min && d.Duration <= max
+}
@@ -2,6 +2,9 @@ package utils
 
 import (
 	"flag"
+	"fmt"
+	"hash/crc32"
+	"os"
 )
 
 //ElevatorID is the ID of this Elevator, is set when running execute with id flag. Defaults to 0.
@@ -10,69 +13,101 @@ var ELEVATOR_ID int
 //ElevatorPort is the port number of the elevator server
 var ELEVATOR_PORT int
 
-func init() {
-	flag.IntVar(&ELEVATOR_ID, "id", 0, "ID of this Elevator")
-	flag.IntVar(&ELEVATOR_PORT, "port", 15657, "Port of the Elevator")
-	flag.Parse()
-}
-
+//ELEVATOR_IO is the hardware implementation used by the driver: tcp, fake or record
+var ELEVATOR_IO string
 
+//FLOOR_NUM is the number of floors, read from the config file at startup
+var FLOOR_NUM int
 
-//Elevator Settings
-const (
+//ELEVATOR_MAX_NUM is max number of elevators in system, read from the config file at startup
+var ELEVATOR_MAX_NUM int
 
-	//FLOOR_NUM is the number of floors
-	FLOOR_NUM = 4
+//SUPERVISE is set with the supervise flag. The process then only runs the elevator as a child process and restarts it when it dies.
+var SUPERVISE bool
 
-	// ORDER_TYPE_NUM is the number of order types
-	ORDER_TYPE_NUM = 3
+//RECORD_FILE is set with the record flag. Every published event is then written to this file, see the replay command.
+var RECORD_FILE string
 
-	// ELEVATOR_MAX_NUM is max number of elevators in system
-	ELEVATOR_MAX_NUM = 3
+//HEARTBEAT_PORT is the local UDP port of the supervisor. It is set by the supervisor when starting the elevator, 0 means not supervised.
+var HEARTBEAT_PORT int
 
-	// DOOR_OPEN_TIME is how long door is open on floor arrival in seconds
-	DOOR_OPEN_TIME = 3
-
-	// TRAVEL_TIME is the time it takes in seconds for the elevator to move for one floor to another
-	TRAVEL_TIME = 3
-
-	// MAX_OBSTRUCT_TIME is time in seconds before elevator is considered unavailable
-	MAX_OBSTRUCT_TIME = 7
-
-	// MAX_TRAVEL_TIME is the longest time in seconds between floors
-	MAX_TRAVEL_TIME = 6
-
-	// MAX_DECIDE_TIME is the max time in milliseconds for agreeing on an order
-	MAX_DECIDE_TIME = 500
-
-	// ADD ELEVATOR SETTINGS HERE
-)
+// ORDER_TYPE_NUM is the number of order types. This is given by the buttons of the hardware and is not configurable.
+const ORDER_TYPE_NUM = 3
 
-//Connection Settings
-const (
+// DEFAULT_CONFIG_FILE is used when no -config flag is given. If it does not exist the default settings are used.
+const DEFAULT_CONFIG_FILE = "config.json"
 
-	//CONNECTION_DATA_PORT is the UDP port used to send data packets
-	CONNECTION_DATA_PORT = 12067
+var configFile string
+var floors, maxElevators int
 
-	//CONNECTION_CHECK_PORT is the UDP port used to send awake messages
-	CONNECTION_CHECK_PORT = 12068
+func init() {
+	flag.IntVar(&ELEVATOR_ID, "id", 0, "ID of this Elevator")
+	flag.IntVar(&ELEVATOR_PORT, "port", 15657, "Port of the Elevator")
+	flag.StringVar(&ELEVATOR_IO, "io", "tcp", "Elevator hardware: tcp, fake or record")
+	flag.StringVar(&configFile, "config", DEFAULT_CONFIG_FILE, "Config file")
+	flag.IntVar(&floors, "floors", 0, "Number of floors, overrides the config file")
+	flag.IntVar(&maxElevators, "elevators", 0, "Max number of elevators, overrides the config file")
+	flag.BoolVar(&SUPERVISE, "supervise", false, "Run the elevator as a child process and restart it if it crashes or stalls")
+	flag.StringVar(&RECORD_FILE, "record", "", "Record all events to this file")
+	flag.IntVar(&HEARTBEAT_PORT, "heartbeat", 0, "Local port of the supervisor, set by the supervisor")
+}
 
-	//CONNECTION_ACK_PORT is the UDP port used to send Ack messages
-	CONNECTION_ACK_PORT = 12069
+//Init parses the flags, loads and validates the config file. Has to be called before any module is started.
+func Init() error {
+	flag.Parse()
 
-	//CONNECTION_CHECK_INTERVAL is the interval between awake messages in milliseconds
-	CONNECTION_CHECK_INTERVAL = 100
+	settings, err := LoadSettings(configFile)
+	if os.IsNotExist(err) && configFile == DEFAULT_CONFIG_FILE {
+		fmt.Println("No " + DEFAULT_CONFIG_FILE + " found, using default settings")
+	} else if err != nil {
+		return err
+	}
+	if floors != 0 {
+		settings.Building.Floors = floors
+	}
+	if maxElevators != 0 {
+		settings.Building.MaxElevators = maxElevators
+	}
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	Config = settings
+	FLOOR_NUM = settings.Building.Floors
+	ELEVATOR_MAX_NUM = settings.Building.MaxElevators
+	return nil
+}
 
-	//CONNECTION_CHECK_TRESHOLD is the number of awake messages that has to be lost for connection to be considered lost. Dropout latency = Interval * Treshold
-	CONNECTION_CHECK_TRESHOLD = 10
+//SameBuilding checks that a peer uses the same building geometry as this elevator
+func SameBuilding(floors int, maxElevators int) bool {
+	return floors == FLOOR_NUM && maxElevators == ELEVATOR_MAX_NUM
+}
 
-	//ACK_TIMEOUT is how long network module waits for ack. If all acks are not in before timeout, the packet is resent.
-	ACK_TIMEOUT = 15
+//AssignmentHash is the CRC32 of the Assignment settings. All elevators must use the same, so it is sent to the
+//other elevators to be checked with SameAssignment.
+func AssignmentHash() uint32 {
+	return crc32.ChecksumIEEE([]byte(fmt.Sprintf("%+v", Config.Assignment)))
+}
 
-	//ACK_ATTEMPTS is the number of times the network module tries to resend a packet after timout.
-	ACK_ATTEMPTS = 30
+//SameAssignment checks that a peer uses the same Assignment settings as this elevator
+func SameAssignment(hash uint32) bool {
+	return hash == AssignmentHash()
+}
 
-	//RX_PACKET_REGISTER_LENGTH is the number of packet IDs stored to prevent duplicates.
-	RX_PACKET_REGISTER_LENGTH = 36
-	
-)
+//ReloadLogging reads the logging settings from the config file again. The other settings can not be changed while
+//running, so they are kept.
+func ReloadLogging() (LoggingSettings, error) {
+	settings, err := LoadSettings(configFile)
+	if os.IsNotExist(err) && configFile == DEFAULT_CONFIG_FILE {
+		settings = DefaultSettings()
+	} else if err != nil {
+		return LoggingSettings{}, err
+	}
+	logging := settings.Logging
+	settings = Config
+	settings.Logging = logging
+	if err := settings.Validate(); err != nil {
+		return LoggingSettings{}, err
+	}
+	return logging, nil
+}
@@ -2,28 +2,65 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 	"time"
 
 	"./elevator"
 	"./elevator/eventManager"
 	"./elevator/log"
+	"./elevator/supervisor"
+	"./elevator/utils"
 )
 
 func main() {
-	log.Init()
-	fmt.Println("\n\n    ~('-'~) \\('-')/  Elevator Project Started \\('-')/ (~'-')~ \n\n\n")
+	if err := utils.Init(); err != nil {
+		fmt.Println("Invalid configuration:", err)
+		os.Exit(1)
+	}
+	if err := log.Init(logSettings(utils.Config.Logging)); err != nil {
+		fmt.Println("Could not open log file:", err)
+		os.Exit(1)
+	}
+	go reloadLoggingOnHangup()
+	if utils.SUPERVISE {
+		if err := supervisor.Run(os.Args[1:]); err != nil {
+			fmt.Println("Supervisor failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Print("\n\n    ~('-'~) \\('-')/  Elevator Project Started \\('-')/ (~'-')~ \n\n\n\n")
+
+	if err := elevator.InitElevatorIO(utils.ELEVATOR_IO); err != nil {
+		fmt.Println("Could not connect to elevator:", err)
+		os.Exit(1)
+	}
 
 	runtime.GOMAXPROCS(runtime.NumCPU())
-	eventManager.InitEventManager()
+	eventManager.InitEventManager(utils.Config.Logging.Events)
+	if utils.RECORD_FILE != "" {
+		if err := eventManager.StartRecording(utils.RECORD_FILE, elevator.RecordingMeta()); err != nil {
+			fmt.Println("Could not start recording:", err)
+			os.Exit(1)
+		}
+	}
 
+	go elevator.ControlModule()
+	go elevator.MetricsModule()
+	go elevator.DashboardModule()
+	go elevator.OperatorModule()
 	go elevator.ControllerModule()
 	go elevator.AssignerModule()
 	go elevator.ActiveOrdersModule()
+	go elevator.ElectionModule()
 	time.Sleep(1 * time.Second)
 	go elevator.NetworkModule()
 	time.Sleep(1 * time.Second)
 	go elevator.DriverModule()
+	go elevator.HeartbeatModule()
 
 	elevator.StartElevator()
 	fmt.Printf("\n\n")
@@ -31,3 +68,30 @@ func main() {
 		time.Sleep(time.Second)
 	}
 }
+
+func logSettings(logging utils.LoggingSettings) log.Settings {
+	settings := logging.LogSettings()
+	if utils.SUPERVISE && settings.File != "" {
+		// the elevator started by the supervisor writes to the log file
+		settings.File += ".supervisor"
+	}
+	return settings
+}
+
+//reloadLoggingOnHangup reads the logging settings from the config file again on SIGHUP, so log levels can be
+//changed without restarting the elevator. The log file is also reopened, so it can be moved away.
+func reloadLoggingOnHangup() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for range hangup {
+		logging, err := utils.ReloadLogging()
+		if err == nil {
+			err = log.Init(logSettings(logging))
+		}
+		if err != nil {
+			log.PrintErr("Could not reload logging settings:", err)
+			continue
+		}
+		log.PrintInf("Reloaded logging settings")
+	}
+}
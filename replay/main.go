@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"../elevator"
+	"../elevator/eventManager"
+	"../elevator/log"
+	"../elevator/utils"
+)
+
+//Replays a recording made with the -record flag of the elevator. The controller, assigner, active orders and
+//election modules are started with a fake elevator, and the driver, network and operator events of the recording are
+//published at the times they were recorded. The events made by the modules are then compared to the recording.
+func main() {
+	var out string
+	var speed float64
+	var tail time.Duration
+	flag.StringVar(&out, "out", "", "Write the events of the replay to this file")
+	flag.Float64Var(&speed, "speed", 1, "Replay speed, only 1 is exact as the timers of the modules are not sped up")
+	flag.DurationVar(&tail, "tail", time.Second, "How long to keep running after the end of the recording")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: replay [flags] recording")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || speed <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	header, records, err := eventManager.ReadRecording(flag.Arg(0))
+	if err != nil && len(records) == 0 {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Replaying the events read before the error:", err)
+	}
+
+	// the elevator is set up like the recorded one, unless overridden on the command line
+	for name, key := range map[string]string{"id": "ElevatorID", "floors": "Floors", "elevators": "MaxElevators"} {
+		if v, ok := header.Meta[key]; ok && !isFlagSet(name) {
+			flag.Set(name, strconv.Itoa(v))
+		}
+	}
+	if err := utils.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		os.Exit(1)
+	}
+
+	// the cab order backup of the replay must not touch the backup of a real elevator
+	dir, err := ioutil.TempDir("", "replay")
+	utils.CheckError(err)
+	defer os.RemoveAll(dir)
+	if out == "" {
+		out = filepath.Join(dir, "replay.rec")
+	} else if out, err = filepath.Abs(out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	utils.CheckError(os.Chdir(dir))
+
+	// the log file is not written, it would be in the temporary directory
+	logSettings := utils.Config.Logging.LogSettings()
+	logSettings.File, logSettings.Stdout = "", true
+	utils.CheckError(log.Init(logSettings))
+	elevator.SetElevatorIO(elevator.NewFakeElevatorIO(utils.FLOOR_NUM))
+	eventManager.InitEventManager(utils.Config.Logging.Events)
+	utils.CheckError(eventManager.StartRecording(out, elevator.RecordingMeta()))
+	start := time.Now()
+
+	fmt.Printf("Replaying %d events of elevator %d\n", len(records), utils.ELEVATOR_ID)
+	go elevator.StartReplayModules()
+	elevator.Replay(header, records, start, speed)
+	if len(records) > 0 {
+		end := records[len(records)-1].Time.Sub(header.Start)
+		time.Sleep(time.Until(start.Add(time.Duration(float64(end) / speed))))
+	}
+	time.Sleep(tail)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	eventManager.Shutdown(ctx)
+	utils.CheckError(eventManager.StopRecording())
+
+	_, replayed, err := eventManager.ReadRecording(out)
+	utils.CheckError(err)
+	if !compare(records, replayed) {
+		os.Exit(1)
+	}
+}
+
+//compare checks that the events made by the modules are the same in the recording and the replay. Events of
+//different types may come in another order, as they are made by different goroutines, so only the order of the
+//events of each type is compared. Repeated events, like the periodic HallRequestTableEvent, are only compared once,
+//as the number of repeats depends on timing. Returns true if they are the same.
+func compare(recorded, replayed []eventManager.Record) bool {
+	want := localEventsByType(recorded)
+	got := localEventsByType(replayed)
+	same := true
+	for typ, w := range want {
+		g := got[typ]
+		for i := 0; i < len(w) || i < len(g); i++ {
+			if i >= len(w) || i >= len(g) || !bytes.Equal(w[i], g[i]) {
+				fmt.Printf("%s differs at event %d of %d recorded, %d replayed\n", typ, i+1, len(w), len(g))
+				if i < len(w) {
+					fmt.Printf("  recorded: %s\n", w[i])
+				}
+				if i < len(g) {
+					fmt.Printf("  replayed: %s\n", g[i])
+				}
+				same = false
+				break
+			}
+		}
+	}
+	for typ, g := range got {
+		if _, exist := want[typ]; !exist {
+			fmt.Printf("%s was not recorded, but replayed %d times\n", typ, len(g))
+			same = false
+		}
+	}
+	if same {
+		fmt.Println("Replay matches the recording")
+	}
+	return same
+}
+
+func localEventsByType(records []eventManager.Record) map[string][][]byte {
+	events := make(map[string][][]byte)
+	for _, r := range records {
+		if r.Source != eventManager.SourceLocal {
+			continue
+		}
+		prev := events[r.Type]
+		if len(prev) > 0 && bytes.Equal(prev[len(prev)-1], r.Event) {
+			continue
+		}
+		events[r.Type] = append(prev, r.Event)
+	}
+	return events
+}
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"../elevator/simulator"
+)
+
+func main() {
+	cfg := simulator.DefaultConfig()
+	var travelTime, passingTime, btnTime int
+	var script string
+
+	flag.IntVar(&cfg.Port, "port", cfg.Port, "TCP port the driver connects to")
+	flag.IntVar(&cfg.NumFloors, "numFloors", cfg.NumFloors, "Number of floors")
+	flag.IntVar(&cfg.StartFloor, "startFloor", cfg.StartFloor, "Floor the cab starts at")
+	flag.IntVar(&travelTime, "travelTimeBetweenFloors_ms", int(cfg.TravelTimeBetweenFloors/time.Millisecond), "Time to travel from one floor to the next")
+	flag.IntVar(&passingTime, "travelTimePassingFloor_ms", int(cfg.TravelTimePassingFloor/time.Millisecond), "Time the floor sensor is active when passing a floor")
+	flag.IntVar(&btnTime, "btnDepressedTime_ms", int(cfg.BtnDepressedTime/time.Millisecond), "Time a pressed button is held down")
+	flag.BoolVar(&cfg.StopMotorOnDisconnect, "stopMotorOnDisconnect", cfg.StopMotorOnDisconnect, "Stop the motor when the driver disconnects")
+	flag.StringVar(&script, "script", "", "Script file to run, - reads commands from stdin")
+	flag.Parse()
+
+	cfg.TravelTimeBetweenFloors = time.Duration(travelTime) * time.Millisecond
+	cfg.TravelTimePassingFloor = time.Duration(passingTime) * time.Millisecond
+	cfg.BtnDepressedTime = time.Duration(btnTime) * time.Millisecond
+
+	sim, err := simulator.New(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if script != "" {
+		go runScript(sim, script)
+	}
+
+	fmt.Printf("Simulated elevator with %d floors listening on port %d\n", cfg.NumFloors, cfg.Port)
+	if err := sim.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runScript(sim *simulator.Simulator, name string) {
+	in := os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+	if err := sim.RunScript(in, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "script:", err)
+	}
+}
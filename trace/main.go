@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"../elevator/eventManager"
+)
+
+//The stages of an order, in the order they happen. The first event of each stage is used for the latency breakdown.
+var stages = []struct {
+	name   string
+	events []string
+}{
+	{"pressed", []string{"elevator.NewOrderEvent", "elevator.NewCabOrderEvent"}},
+	{"cost", []string{"elevator.CostResultEvent"}},
+	{"check", []string{"elevator.CheckAssignedElevEvent"}},
+	{"agreed", []string{"elevator.AssignedOKEvent"}},
+	{"assigned", []string{"elevator.AssignedEvent"}},
+	{"completed", []string{"elevator.OrderCompleteEvent"}},
+}
+
+//step is one event of a trace, as seen by one elevator
+type step struct {
+	time time.Time
+	// node is the elevator that recorded the event
+	node   int
+	source string
+	typ    string
+	// elevatorID is the ElevatorID field of the event
+	elevatorID int
+	event      json.RawMessage
+}
+
+type trace struct {
+	id        string
+	floor     int
+	orderType int
+	steps     []step
+}
+
+//Reconstructs the lifecycle of orders from the recordings of all elevators, made with the -record flag. Without
+//-trace a latency breakdown of every order is printed, with -trace all events of one order.
+func main() {
+	var traceID string
+	var hallOnly bool
+	flag.StringVar(&traceID, "trace", "", "Print all events of this trace")
+	flag.BoolVar(&hallOnly, "hall", false, "Only show hall calls")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: trace [flags] recording...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	traces := make(map[string]*trace)
+	for _, filename := range flag.Args() {
+		header, records, err := eventManager.ReadRecording(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if len(records) == 0 {
+				os.Exit(1)
+			}
+		}
+		node, ok := header.Meta["ElevatorID"]
+		if !ok {
+			node = -1
+		}
+		for _, r := range records {
+			addRecord(traces, node, r)
+		}
+	}
+
+	if traceID != "" {
+		t, exist := traces[traceID]
+		if !exist {
+			fmt.Fprintln(os.Stderr, "No events with trace", traceID)
+			os.Exit(1)
+		}
+		printSteps(t)
+		return
+	}
+
+	var sorted []*trace
+	for _, t := range traces {
+		if hallOnly && t.orderType == 2 {
+			continue
+		}
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].steps[0].time.Before(sorted[j].steps[0].time) })
+	printBreakdown(sorted)
+}
+
+//addRecord adds the record to the traces it belongs to
+func addRecord(traces map[string]*trace, node int, r eventManager.Record) {
+	var fields struct {
+		TraceID   string
+		TraceIDs  []string
+		Floor     *int
+		OrderType *int
+	}
+	if err := json.Unmarshal(r.Event, &fields); err != nil {
+		return
+	}
+	ids := fields.TraceIDs
+	if fields.TraceID != "" {
+		ids = append(ids, fields.TraceID)
+	}
+	for _, id := range ids {
+		t, exist := traces[id]
+		if !exist {
+			t = &trace{id: id, floor: -1, orderType: -1}
+			traces[id] = t
+		}
+		if fields.Floor != nil && fields.OrderType != nil {
+			t.floor, t.orderType = *fields.Floor, *fields.OrderType
+		}
+		t.steps = append(t.steps, step{r.Time, node, r.Source, r.Type, r.ElevatorID, r.Event})
+	}
+}
+
+func sortSteps(t *trace) {
+	sort.SliceStable(t.steps, func(i, j int) bool { return t.steps[i].time.Before(t.steps[j].time) })
+}
+
+//printSteps prints every event of the trace. Events sent over the network are seen by more than one elevator.
+func printSteps(t *trace) {
+	sortSteps(t)
+	fmt.Printf("Trace %s, floor %d, %s\n", t.id, t.floor, orderTypeName(t.orderType))
+	start := t.steps[0].time
+	for _, s := range t.steps {
+		fmt.Printf("%10v  elevator %d  %-7s  %-30s %s\n", s.time.Sub(start).Round(time.Millisecond), s.node, s.source,
+			s.typ, s.event)
+	}
+}
+
+//printBreakdown prints the time from the button press until the first event of each stage
+func printBreakdown(traces []*trace) {
+	fmt.Printf("%-24s %5s %-9s", "trace", "floor", "type")
+	for _, stage := range stages[1:] {
+		fmt.Printf(" %10s", stage.name)
+	}
+	fmt.Printf("  %s\n", "served by")
+	for _, t := range traces {
+		sortSteps(t)
+		first := firstOfStages(t)
+		fmt.Printf("%-24s %5d %-9s", t.id, t.floor, orderTypeName(t.orderType))
+		for i := range stages[1:] {
+			if first[0] == nil || first[i+1] == nil {
+				fmt.Printf(" %10s", "-")
+				continue
+			}
+			fmt.Printf(" %10v", first[i+1].time.Sub(first[0].time).Round(time.Millisecond))
+		}
+		servedBy := "-"
+		if completed := first[len(stages)-1]; completed != nil {
+			servedBy = fmt.Sprint(completed.elevatorID)
+		}
+		fmt.Printf("  %s\n", servedBy)
+	}
+}
+
+//firstOfStages returns the first step of each stage, nil if the trace has not reached the stage
+func firstOfStages(t *trace) []*step {
+	first := make([]*step, len(stages))
+	for i := range t.steps {
+		s := &t.steps[i]
+		for j, stage := range stages {
+			for _, typ := range stage.events {
+				if s.typ == typ && first[j] == nil {
+					first[j] = s
+				}
+			}
+		}
+	}
+	return first
+}
+
+func orderTypeName(orderType int) string {
+	switch orderType {
+	case 0:
+		return "hall up"
+	case 1:
+		return "hall down"
+	case 2:
+		return "cab"
+	}
+	return "unknown"
+}